@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthConfig protects a capture endpoint with HTTP Basic, a static header API key or a query token
+type AuthConfig struct {
+	Type     string `json:"type"` // basic, header or query
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Header   string `json:"header,omitempty"` // header name for type "header" (default X-API-Key)
+	Query    string `json:"query,omitempty"`  // parameter name for type "query" (default token)
+	Value    string `json:"value,omitempty"`  // expected API key or token
+}
+
+// AuthResult is the credential check outcome stored on the captured request
+type AuthResult struct {
+	Type  string `json:"type"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+const flagUnauthorized = "unauthorized"
+
+func (cfg *AuthConfig) validate() error {
+	switch cfg.Type {
+	case "basic":
+		if cfg.Username == "" && cfg.Password == "" {
+			return fmt.Errorf("basic auth needs a username or password")
+		}
+	case "header", "query":
+		if cfg.Value == "" {
+			return fmt.Errorf("%s auth needs a value", cfg.Type)
+		}
+	default:
+		return fmt.Errorf("unknown auth type %q", cfg.Type)
+	}
+	return nil
+}
+
+func checkAuth(cfg *AuthConfig, r *http.Request) AuthResult {
+	result := AuthResult{Type: cfg.Type}
+
+	switch cfg.Type {
+	case "basic":
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			result.Error = "missing basic credentials"
+		} else if !secureEqual(username, cfg.Username) || !secureEqual(password, cfg.Password) {
+			result.Error = fmt.Sprintf("wrong credentials for user %q", username)
+		}
+
+	case "header":
+		name := cfg.Header
+		if name == "" {
+			name = "X-API-Key"
+		}
+		if value := r.Header.Get(name); value == "" {
+			result.Error = fmt.Sprintf("missing %s header", name)
+		} else if !secureEqual(value, cfg.Value) {
+			result.Error = fmt.Sprintf("wrong %s header value", name)
+		}
+
+	case "query":
+		name := cfg.Query
+		if name == "" {
+			name = "token"
+		}
+		if value := r.URL.Query().Get(name); value == "" {
+			result.Error = fmt.Sprintf("missing %s query parameter", name)
+		} else if !secureEqual(value, cfg.Value) {
+			result.Error = fmt.Sprintf("wrong %s query parameter", name)
+		}
+
+	default:
+		result.Error = fmt.Sprintf("unknown auth type %q", cfg.Type)
+	}
+
+	result.Valid = result.Error == ""
+	return result
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// AdminConfig protects the web UI, /api/*, /ws and file downloads.
+// Capture endpoints under /webhook stay public.
+type AdminConfig struct {
+	Token          string   `json:"token,omitempty"`          // empty leaves the admin surface open
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"` // extra origins allowed to open /ws ("*" for any)
+}
+
+var adminConfig AdminConfig
+
+// requireAdmin accepts the admin token as a bearer token, an X-Admin-Token header,
+// the password of HTTP Basic auth (any username) or an access_token query parameter
+// for WebSocket clients that cannot set headers. Preflight requests carry no
+// credentials, so they are answered here and never reach the handler.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Token")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if adminConfig.Token == "" || isAdmin(r) {
+			next(w, r)
+			return
+		}
+
+		log.Printf("Admin authentication failed for %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="webhook-test-server admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+}
+
+func isAdmin(r *http.Request) bool {
+	// The auth scheme is case-insensitive (RFC 7235)
+	if scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " "); strings.EqualFold(scheme, "Bearer") && secureEqual(strings.TrimSpace(token), adminConfig.Token) {
+		return true
+	}
+	if token := r.Header.Get("X-Admin-Token"); token != "" && secureEqual(token, adminConfig.Token) {
+		return true
+	}
+	if _, password, ok := r.BasicAuth(); ok && secureEqual(password, adminConfig.Token) {
+		return true
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" && secureEqual(token, adminConfig.Token) {
+		return true
+	}
+	return false
+}
+
+// checkOrigin allows WebSocket upgrades from non-browser clients, the server's own
+// origin and any configured allowed origins
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range adminConfig.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	log.Printf("Rejecting WebSocket from origin %s", origin)
+	return false
+}
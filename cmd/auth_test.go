@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withAdminToken(t *testing.T, token string) {
+	t.Helper()
+	saved := adminConfig
+	adminConfig = AdminConfig{Token: token}
+	t.Cleanup(func() { adminConfig = saved })
+}
+
+func TestRequireAdmin(t *testing.T) {
+	withAdminToken(t, "s3cret")
+
+	tests := []struct {
+		name       string
+		target     string
+		header     string
+		value      string
+		basic      bool
+		wantStatus int
+	}{
+		{"no credentials", "/api/requests", "", "", false, http.StatusUnauthorized},
+		{"bearer", "/api/requests", "Authorization", "Bearer s3cret", false, http.StatusOK},
+		{"lower-case bearer", "/api/requests", "Authorization", "bearer s3cret", false, http.StatusOK},
+		{"upper-case bearer", "/api/requests", "Authorization", "BEARER s3cret", false, http.StatusOK},
+		{"bearer with extra space", "/api/requests", "Authorization", "Bearer  s3cret", false, http.StatusOK},
+		{"wrong bearer", "/api/requests", "Authorization", "Bearer nope", false, http.StatusUnauthorized},
+		{"other scheme", "/api/requests", "Authorization", "Token s3cret", false, http.StatusUnauthorized},
+		{"admin header", "/api/requests", "X-Admin-Token", "s3cret", false, http.StatusOK},
+		{"basic password", "/api/requests", "", "", true, http.StatusOK},
+		{"access_token query", "/ws?access_token=s3cret", "", "", false, http.StatusOK},
+		{"wrong access_token", "/ws?access_token=nope", "", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			if tt.basic {
+				r.SetBasicAuth("anyone", "s3cret")
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireAdminOpenWithoutToken(t *testing.T) {
+	withAdminToken(t, "")
+
+	called := false
+	handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) { called = true })
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/requests", nil))
+	if !called {
+		t.Error("handler not called when no admin token is configured")
+	}
+}
+
+func TestRequireAdminPreflightReturnsNoFile(t *testing.T) {
+	withAdminToken(t, "s3cret")
+
+	key := storedFileKey("req-preflight", "0", "secret.txt")
+	fileStorageMux.Lock()
+	fileStorage[key] = []byte("captured file contents")
+	fileStorageMux.Unlock()
+	t.Cleanup(func() {
+		fileStorageMux.Lock()
+		delete(fileStorage, key)
+		fileStorageMux.Unlock()
+	})
+
+	r := httptest.NewRequest("OPTIONS", downloadURL(key), nil)
+	w := httptest.NewRecorder()
+	requireAdmin(handleFileDownload)(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("preflight returned a body: %q", w.Body.String())
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Error("preflight has no CORS headers")
+	}
+}
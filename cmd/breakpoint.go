@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakpointConfig holds every request on an endpoint open until it is released
+// through the API or the timeout expires
+type BreakpointConfig struct {
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"` // auto-release after this long (default 60)
+	TimeoutAction  string `json:"timeoutAction,omitempty"`  // respond (default) with the standard reply, or drop
+}
+
+// BreakpointState is stored on the capture and tracks what happened to a held request
+type BreakpointState struct {
+	Status     string            `json:"status"` // pending, released, dropped, timeout or abandoned
+	HeldAt     time.Time         `json:"heldAt"`
+	ExpiresAt  time.Time         `json:"expiresAt"`
+	ReleasedAt *time.Time        `json:"releasedAt,omitempty"`
+	Response   *BreakpointAction `json:"response,omitempty"`
+}
+
+// BreakpointAction is how a held request is answered
+type BreakpointAction struct {
+	Status  int               `json:"status,omitempty"` // default 200
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Drop    bool              `json:"drop,omitempty"` // close the connection without a response
+}
+
+// BreakpointEvent is broadcast over /ws when a held request is resolved
+type BreakpointEvent struct {
+	Event      string          `json:"event"`
+	RequestID  string          `json:"requestId"`
+	Breakpoint BreakpointState `json:"breakpoint"`
+}
+
+var (
+	heldRequests    = make(map[string]chan BreakpointAction)
+	heldRequestsMux sync.Mutex
+)
+
+func (cfg *BreakpointConfig) validate() error {
+	switch cfg.TimeoutAction {
+	case "", "respond", "drop":
+		return nil
+	default:
+		return fmt.Errorf("unknown breakpoint timeoutAction %q", cfg.TimeoutAction)
+	}
+}
+
+func (cfg *BreakpointConfig) timeout() time.Duration {
+	if cfg.TimeoutSeconds > 0 {
+		return time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// holdRequest captures the request as pending and blocks until it is released,
+// times out or the sender disconnects
+func holdRequest(w http.ResponseWriter, r *http.Request, cfg *BreakpointConfig, webhookReq WebhookRequest) {
+	release := make(chan BreakpointAction, 1)
+	heldRequestsMux.Lock()
+	heldRequests[webhookReq.ID] = release
+	heldRequestsMux.Unlock()
+
+	defer func() {
+		heldRequestsMux.Lock()
+		delete(heldRequests, webhookReq.ID)
+		heldRequestsMux.Unlock()
+	}()
+
+	now := time.Now()
+	state := BreakpointState{
+		Status:    "pending",
+		HeldAt:    now,
+		ExpiresAt: now.Add(cfg.timeout()),
+	}
+	webhookReq.Breakpoint = &state
+	addRequest(webhookReq)
+	log.Printf("Holding request %s until %s", webhookReq.ID, state.ExpiresAt.Format(time.RFC3339))
+
+	var action BreakpointAction
+	timer := time.NewTimer(cfg.timeout())
+	defer timer.Stop()
+
+	select {
+	case action = <-release:
+		state.Status = "released"
+		if action.Drop {
+			state.Status = "dropped"
+		}
+	case <-timer.C:
+		state.Status = "timeout"
+		action = BreakpointAction{Drop: cfg.TimeoutAction == "drop"}
+	case <-r.Context().Done():
+		state.Status = "abandoned"
+	}
+
+	releasedAt := time.Now()
+	state.ReleasedAt = &releasedAt
+	if state.Status != "abandoned" {
+		state.Response = &action
+	}
+	updateRequest(webhookReq.ID, func(request *WebhookRequest) {
+		request.Breakpoint = &state
+	})
+	go broadcastEvent(BreakpointEvent{Event: "breakpoint." + state.Status, RequestID: webhookReq.ID, Breakpoint: state})
+	log.Printf("Held request %s %s after %s", webhookReq.ID, state.Status, releasedAt.Sub(now).Round(time.Millisecond))
+
+	switch {
+	case state.Status == "abandoned":
+		return
+	case action.Drop:
+		// Abort the handler so the server closes the connection without responding
+		panic(http.ErrAbortHandler)
+	case action.Status == 0 && action.Body == "" && len(action.Headers) == 0:
+		writeWebhookResponse(w)
+	default:
+		for name, value := range action.Headers {
+			w.Header().Set(name, value)
+		}
+		status := action.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(action.Body))
+	}
+}
+
+// handleBreakpoints serves /api/breakpoints and /api/breakpoints/{id}/release
+func handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/breakpoints"), "/")
+	if path == "" && r.Method == "GET" {
+		heldRequestsMux.Lock()
+		ids := make([]string, 0, len(heldRequests))
+		for id := range heldRequests {
+			ids = append(ids, id)
+		}
+		heldRequestsMux.Unlock()
+		sort.Strings(ids)
+
+		held := []WebhookRequest{}
+		for _, id := range ids {
+			if request, ok := findRequest(id); ok {
+				held = append(held, request)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"requests": held, "count": len(held)})
+		return
+	}
+
+	id, action, _ := strings.Cut(path, "/")
+	if action != "release" || r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var release BreakpointAction
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+			http.Error(w, "Invalid release JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if release.Status != 0 && (release.Status < 100 || release.Status > 599) {
+		http.Error(w, "status must be between 100 and 599", http.StatusBadRequest)
+		return
+	}
+
+	heldRequestsMux.Lock()
+	ch, exists := heldRequests[id]
+	if exists {
+		delete(heldRequests, id)
+	}
+	heldRequestsMux.Unlock()
+
+	if !exists {
+		http.Error(w, "No held request with that ID", http.StatusNotFound)
+		return
+	}
+	ch <- release
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Request released",
+		"id":      id,
+	})
+}
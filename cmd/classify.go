@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ClassifierRule labels captures with the provider that sent them and the event type.
+// A rule matches when all of its header and body conditions hold. Rules from the
+// config file are tried before the built-in ones, and the first match wins.
+type ClassifierRule struct {
+	Provider    string `json:"provider"`
+	Header      string `json:"header,omitempty"`      // header that must be present
+	HeaderValue string `json:"headerValue,omitempty"` // prefix the header value must start with
+	BodyField   string `json:"bodyField,omitempty"`   // dotted path that must exist in the body, e.g. "event.type" or "0.id"
+	BodyValue   string `json:"bodyValue,omitempty"`   // value the body field must have
+	EventHeader string `json:"eventHeader,omitempty"` // header holding the event type
+	EventField  string `json:"eventField,omitempty"`  // body path holding the event type; "a|b" tries each in turn
+	Event       string `json:"event,omitempty"`       // fixed event type when the above find nothing
+}
+
+// Rules from the config file
+var classifierRules []ClassifierRule
+
+// Built-in rules, most specific first
+var builtinClassifierRules = []ClassifierRule{
+	{Provider: "github", Header: "X-GitHub-Event", EventHeader: "X-GitHub-Event"},
+	{Provider: "gitlab", Header: "X-Gitlab-Event", EventHeader: "X-Gitlab-Event"},
+	{Provider: "bitbucket", Header: "X-Event-Key", EventHeader: "X-Event-Key"},
+	{Provider: "stripe", Header: "Stripe-Signature", EventField: "type"},
+	{Provider: "stripe", Header: "User-Agent", HeaderValue: "Stripe/", EventField: "type"},
+	{Provider: "slack", Header: "X-Slack-Signature", EventField: "event.type|payload.type|command|type"},
+	{Provider: "shopify", Header: "X-Shopify-Topic", EventHeader: "X-Shopify-Topic"},
+	{Provider: "twilio", Header: "X-Twilio-Signature", EventField: "MessageStatus|SmsStatus|CallStatus"},
+	{Provider: "paypal", Header: "Paypal-Transmission-Id", EventField: "event_type"},
+	{Provider: "zoom", Header: "X-Zm-Signature", EventField: "event"},
+	{Provider: "zoom", BodyField: "event", BodyValue: "endpoint.url_validation", EventField: "event"},
+	{Provider: "meta", Header: "X-Hub-Signature-256", EventField: "object"},
+	{Provider: "sns", Header: "X-Amz-Sns-Message-Type", EventHeader: "X-Amz-Sns-Message-Type"},
+	{Provider: "eventgrid", Header: "Aeg-Event-Type", EventField: "0.eventType|0.type|eventType|type"},
+	{Provider: "standard-webhooks", Header: "Webhook-Id", EventField: "type"},
+	{Provider: "svix", Header: "Svix-Id", EventField: "type"},
+	{Provider: "thoughtspot", BodyField: "scheduledReportWebhookNotification", EventField: "notificationType"},
+	{Provider: "thoughtspot", BodyField: "data.data.scheduledReportWebhookNotification", EventField: "data.data.notificationType"},
+}
+
+func (rule *ClassifierRule) validate() error {
+	if rule.Provider == "" {
+		return fmt.Errorf("classifier rule needs a provider")
+	}
+	if rule.Header == "" && rule.BodyField == "" {
+		return fmt.Errorf("classifier rule for %q needs a header or bodyField", rule.Provider)
+	}
+	return nil
+}
+
+// classifyRequest sets Provider and EventType from the first matching rule, falling
+// back to what envelope and CloudEvents decoding found
+func classifyRequest(request *WebhookRequest) {
+	header := http.Header(request.Headers)
+	body := request.Body
+	if body == nil && len(request.RawBody) > 0 {
+		body = decodeInnerPayload(request.RawBody)
+	}
+
+	for _, rules := range [][]ClassifierRule{classifierRules, builtinClassifierRules} {
+		for _, rule := range rules {
+			if rule.matches(header, body) {
+				request.Provider = rule.Provider
+				request.EventType = rule.event(header, body)
+				return
+			}
+		}
+	}
+
+	switch {
+	case request.Envelope != nil:
+		request.Provider = request.Envelope.Type
+		request.EventType = request.Envelope.MessageType
+	case len(request.CloudEvents) > 0:
+		request.Provider = "cloudevents"
+		request.EventType = request.CloudEvents[0].Type
+	}
+}
+
+func (rule *ClassifierRule) matches(header http.Header, body interface{}) bool {
+	if rule.Header != "" {
+		value := header.Get(rule.Header)
+		if value == "" || !strings.HasPrefix(value, rule.HeaderValue) {
+			return false
+		}
+	}
+	if rule.BodyField != "" {
+		value, ok := lookupField(body, rule.BodyField)
+		if !ok || (rule.BodyValue != "" && fmt.Sprint(value) != rule.BodyValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *ClassifierRule) event(header http.Header, body interface{}) string {
+	if rule.EventHeader != "" {
+		for _, name := range strings.Split(rule.EventHeader, "|") {
+			if value := header.Get(name); value != "" {
+				return value
+			}
+		}
+	}
+	if rule.EventField != "" {
+		for _, path := range strings.Split(rule.EventField, "|") {
+			if value, ok := lookupField(body, path); ok && value != nil && fmt.Sprint(value) != "" {
+				return fmt.Sprint(value)
+			}
+		}
+	}
+	return rule.Event
+}
+
+// lookupField follows a dotted path through parsed JSON. Numeric segments index
+// arrays, and string values holding JSON (such as Slack's payload form field) are
+// parsed on the way through.
+func lookupField(value interface{}, path string) (interface{}, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		if text, ok := value.(string); ok {
+			var parsed interface{}
+			if json.Unmarshal([]byte(text), &parsed) != nil {
+				return nil, false
+			}
+			value = parsed
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
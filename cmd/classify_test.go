@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		body         string
+		envelope     *EnvelopeInfo
+		cloudEvents  []CloudEvent
+		wantProvider string
+		wantEvent    string
+	}{
+		{"github", map[string]string{"X-GitHub-Event": "push"}, `{"ref":"main"}`, nil, nil, "github", "push"},
+		{"stripe signature", map[string]string{"Stripe-Signature": "t=1,v1=x"}, `{"type":"invoice.paid"}`, nil, nil, "stripe", "invoice.paid"},
+		{"stripe user agent", map[string]string{"User-Agent": "Stripe/1.0 (+https://stripe.com/docs/webhooks)"}, `{"type":"charge.failed"}`, nil, nil, "stripe", "charge.failed"},
+		{"slack event", map[string]string{"X-Slack-Signature": "v0=x"}, `{"type":"event_callback","event":{"type":"app_mention"}}`, nil, nil, "slack", "app_mention"},
+		{"slack interaction form", map[string]string{"X-Slack-Signature": "v0=x"}, `{"payload":"{\"type\":\"block_actions\"}"}`, nil, nil, "slack", "block_actions"},
+		{"twilio status", map[string]string{"X-Twilio-Signature": "x"}, `{"MessageStatus":"delivered"}`, nil, nil, "twilio", "delivered"},
+		{"zoom validation without signature", nil, `{"event":"endpoint.url_validation","payload":{}}`, nil, nil, "zoom", "endpoint.url_validation"},
+		{"event grid batch", map[string]string{"Aeg-Event-Type": "Notification"}, `[{"eventType":"Microsoft.Storage.BlobCreated"}]`, nil, nil, "eventgrid", "Microsoft.Storage.BlobCreated"},
+		{"thoughtspot", nil, `{"notificationType":"SCHEDULED_REPORT","scheduledReportWebhookNotification":{}}`, nil, nil, "thoughtspot", "SCHEDULED_REPORT"},
+		{"envelope fallback", nil, `{}`, &EnvelopeInfo{Type: "pubsub", MessageType: "message"}, nil, "pubsub", "message"},
+		{"cloudevents fallback", nil, `{}`, nil, []CloudEvent{{Type: "com.example.created"}}, "cloudevents", "com.example.created"},
+		{"unknown", map[string]string{"Content-Type": "application/json"}, `{"hello":"world"}`, nil, nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			request := WebhookRequest{Headers: header, Envelope: tt.envelope, CloudEvents: tt.cloudEvents}
+			if err := json.Unmarshal([]byte(tt.body), &request.Body); err != nil {
+				t.Fatal(err)
+			}
+
+			classifyRequest(&request)
+			if request.Provider != tt.wantProvider || request.EventType != tt.wantEvent {
+				t.Errorf("got %q/%q, want %q/%q", request.Provider, request.EventType, tt.wantProvider, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestClassifierConfigRulesComeFirst(t *testing.T) {
+	saved := classifierRules
+	classifierRules = []ClassifierRule{{Provider: "internal", Header: "X-Github-Event", EventField: "action", Event: "unknown"}}
+	t.Cleanup(func() { classifierRules = saved })
+
+	request := WebhookRequest{Headers: map[string][]string{"X-Github-Event": {"push"}}, Body: map[string]interface{}{"action": "opened"}}
+	classifyRequest(&request)
+	if request.Provider != "internal" || request.EventType != "opened" {
+		t.Errorf("got %q/%q, want internal/opened", request.Provider, request.EventType)
+	}
+
+	request = WebhookRequest{Headers: map[string][]string{"X-Github-Event": {"push"}}, Body: map[string]interface{}{}}
+	classifyRequest(&request)
+	if request.EventType != "unknown" {
+		t.Errorf("event = %q, want the fixed fallback", request.EventType)
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	var body interface{}
+	json.Unmarshal([]byte(`{"event":{"type":"x"},"items":[{"id":7}],"payload":"{\"type\":\"y\"}","n":null}`), &body)
+
+	tests := []struct {
+		path   string
+		want   interface{}
+		wantOK bool
+	}{
+		{"event.type", "x", true},
+		{"items.0.id", float64(7), true},
+		{"payload.type", "y", true},
+		{"n", nil, true},
+		{"items.1.id", nil, false},
+		{"items.x", nil, false},
+		{"event.missing", nil, false},
+		{"event.type.deeper", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := lookupField(body, tt.path)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("got %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
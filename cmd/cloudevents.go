@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CloudEvent is the normalised form of a CloudEvents message in binary or structured mode
+type CloudEvent struct {
+	Mode            string                 `json:"mode"` // binary, structured or batch
+	SpecVersion     string                 `json:"specversion"`
+	ID              string                 `json:"id"`
+	Source          string                 `json:"source"`
+	Type            string                 `json:"type"`
+	Subject         string                 `json:"subject,omitempty"`
+	Time            string                 `json:"time,omitempty"`
+	DataContentType string                 `json:"datacontenttype,omitempty"`
+	DataSchema      string                 `json:"dataschema,omitempty"`
+	Data            interface{}            `json:"data,omitempty"`
+	Extensions      map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// decodeCloudEvents recognises CloudEvents in the request and returns them, or nil
+func decodeCloudEvents(r *http.Request, body []byte) []CloudEvent {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case mediaType == "application/cloudevents+json":
+		var attributes map[string]json.RawMessage
+		if json.Unmarshal(body, &attributes) != nil {
+			return nil
+		}
+		return []CloudEvent{structuredCloudEvent("structured", attributes)}
+
+	case mediaType == "application/cloudevents-batch+json":
+		var batch []map[string]json.RawMessage
+		if json.Unmarshal(body, &batch) != nil {
+			return nil
+		}
+		events := make([]CloudEvent, 0, len(batch))
+		for _, attributes := range batch {
+			events = append(events, structuredCloudEvent("batch", attributes))
+		}
+		return events
+
+	case r.Header.Get("Ce-Specversion") != "":
+		return []CloudEvent{binaryCloudEvent(r, body)}
+	}
+	return nil
+}
+
+// binaryCloudEvent reads the attributes from ce-* headers; the body is the data
+func binaryCloudEvent(r *http.Request, body []byte) CloudEvent {
+	event := CloudEvent{
+		Mode:            "binary",
+		DataContentType: r.Header.Get("Content-Type"),
+		Data:            cloudEventData(r.Header.Get("Content-Type"), body),
+	}
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, "ce-") || len(values) == 0 {
+			continue
+		}
+		attribute := strings.TrimPrefix(lower, "ce-")
+		// The HTTP binding percent-encodes header values; keep malformed ones as sent
+		value := values[0]
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		if !event.setAttribute(attribute, value) {
+			if event.Extensions == nil {
+				event.Extensions = make(map[string]interface{})
+			}
+			event.Extensions[attribute] = value
+		}
+	}
+	return event
+}
+
+// structuredCloudEvent reads a JSON-encoded event, where data may be inline or in data_base64
+func structuredCloudEvent(mode string, attributes map[string]json.RawMessage) CloudEvent {
+	event := CloudEvent{Mode: mode}
+	var data, dataBase64 json.RawMessage
+
+	for name, raw := range attributes {
+		switch name {
+		case "data":
+			data = raw
+			continue
+		case "data_base64":
+			dataBase64 = raw
+			continue
+		}
+
+		var value interface{}
+		json.Unmarshal(raw, &value)
+		if text, ok := value.(string); ok && event.setAttribute(name, text) {
+			continue
+		}
+		if event.Extensions == nil {
+			event.Extensions = make(map[string]interface{})
+		}
+		event.Extensions[name] = value
+	}
+
+	if data != nil {
+		var value interface{}
+		json.Unmarshal(data, &value)
+		event.Data = value
+	} else if dataBase64 != nil {
+		var encoded string
+		json.Unmarshal(dataBase64, &encoded)
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			event.Data = cloudEventData(event.DataContentType, decoded)
+		}
+	}
+	return event
+}
+
+// setAttribute fills a context attribute, reporting false for extensions
+func (e *CloudEvent) setAttribute(name, value string) bool {
+	switch name {
+	case "specversion":
+		e.SpecVersion = value
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "type":
+		e.Type = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		e.Time = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	default:
+		return false
+	}
+	return true
+}
+
+// cloudEventData parses JSON data and keeps everything else as text
+func cloudEventData(contentType string, data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return decodeInnerPayload(data)
+	}
+	return string(data)
+}
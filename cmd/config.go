@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ServerConfig is the optional JSON file named by the CONFIG_FILE environment variable
+type ServerConfig struct {
+	Admin       AdminConfig      `json:"admin"`
+	Endpoints   []EndpointConfig `json:"endpoints"`
+	Schedules   []ScheduleConfig `json:"schedules"`
+	Classifiers []ClassifierRule `json:"classifiers"` // tried before the built-in provider rules
+}
+
+// EndpointConfig controls how requests captured on /webhook/{name} are handled.
+// The plain /webhook endpoint uses the name "default".
+type EndpointConfig struct {
+	Name       string            `json:"name"`
+	Auth       *AuthConfig       `json:"auth,omitempty"`
+	Signature  *SignatureConfig  `json:"signature,omitempty"`
+	JWT        *JWTConfig        `json:"jwt,omitempty"`
+	Breakpoint *BreakpointConfig `json:"breakpoint,omitempty"`
+	Forward    *ForwardConfig    `json:"forward,omitempty"`
+	Handshake  *HandshakeConfig  `json:"handshake,omitempty"`
+	Envelope   *EnvelopeConfig   `json:"envelope,omitempty"`
+	Multipart  *MultipartConfig  `json:"multipart,omitempty"`
+}
+
+const defaultEndpointName = "default"
+
+// Endpoint configuration, keyed by endpoint name
+var (
+	endpoints    = make(map[string]EndpointConfig)
+	endpointsMux sync.RWMutex
+)
+
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	var cfg ServerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("parsing config: %w", err)
+	}
+
+	adminConfig = cfg.Admin
+
+	for i := range cfg.Classifiers {
+		if err := cfg.Classifiers[i].validate(); err != nil {
+			return err
+		}
+	}
+	classifierRules = cfg.Classifiers
+
+	for _, endpoint := range cfg.Endpoints {
+		if err := setEndpoint(endpoint); err != nil {
+			return err
+		}
+		log.Printf("Configured endpoint %q", endpoint.Name)
+	}
+
+	for _, schedule := range cfg.Schedules {
+		if _, err := setSchedule(schedule); err != nil {
+			return err
+		}
+		log.Printf("Configured schedule %q (%s)", schedule.Name, schedule.Cron)
+	}
+	return nil
+}
+
+func setEndpoint(endpoint EndpointConfig) error {
+	endpoint.Name = strings.Trim(endpoint.Name, "/")
+	if endpoint.Name == "" {
+		return fmt.Errorf("endpoint name is required")
+	}
+	if endpoint.Auth != nil {
+		if err := endpoint.Auth.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+	if endpoint.Signature != nil {
+		if _, ok := signatureVerifiers[endpoint.Signature.Provider]; !ok {
+			return fmt.Errorf("endpoint %q: unknown signature provider %q", endpoint.Name, endpoint.Signature.Provider)
+		}
+	}
+
+	if endpoint.JWT != nil && endpoint.JWT.JWKSFile == "" && endpoint.JWT.PEMFile == "" {
+		return fmt.Errorf("endpoint %q: jwt needs a jwksFile or pemFile", endpoint.Name)
+	}
+
+	if endpoint.Breakpoint != nil {
+		if err := endpoint.Breakpoint.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+	if endpoint.Forward != nil {
+		if err := endpoint.Forward.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+	if endpoint.Handshake != nil {
+		if err := endpoint.Handshake.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+	if endpoint.Envelope != nil {
+		if err := endpoint.Envelope.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+	if endpoint.Multipart != nil {
+		if err := endpoint.Multipart.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+		}
+	}
+
+	endpointsMux.Lock()
+	endpoints[endpoint.Name] = endpoint
+	endpointsMux.Unlock()
+	return nil
+}
+
+func getEndpoint(name string) (EndpointConfig, bool) {
+	endpointsMux.RLock()
+	defer endpointsMux.RUnlock()
+	endpoint, ok := endpoints[name]
+	return endpoint, ok
+}
+
+// endpointName maps /webhook to "default" and /webhook/{name} to name
+func endpointName(path string) string {
+	name := strings.Trim(strings.TrimPrefix(path, "/webhook"), "/")
+	if name == "" {
+		return defaultEndpointName
+	}
+	return name
+}
+
+func handleAPIEndpoints(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		endpointsMux.RLock()
+		list := make([]EndpointConfig, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			list = append(list, endpoint)
+		}
+		endpointsMux.RUnlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"endpoints": list,
+			"count":     len(list),
+		})
+
+	case "POST":
+		var endpoint EndpointConfig
+		if err := json.NewDecoder(r.Body).Decode(&endpoint); err != nil {
+			http.Error(w, "Invalid endpoint JSON", http.StatusBadRequest)
+			return
+		}
+		if err := setEndpoint(endpoint); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Endpoint %q updated via API", endpoint.Name)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(endpoint)
+
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+		endpointsMux.Lock()
+		_, exists := endpoints[name]
+		delete(endpoints, name)
+		endpointsMux.Unlock()
+
+		if !exists {
+			http.Error(w, "Endpoint not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Endpoint %q removed via API", name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
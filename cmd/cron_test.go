@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := parseCron(expr); err == nil {
+				t.Errorf("parseCron(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string // "" when the schedule never fires
+	}{
+		{"*/15 * * * *", "2024-03-06 10:07:30", "2024-03-06 10:15:00"},
+		{"*/15 * * * *", "2024-03-06 10:15:00", "2024-03-06 10:30:00"},
+		{"5/15 * * * *", "2024-03-06 10:50:00", "2024-03-06 11:05:00"},
+		{"0,30 9-10 * * *", "2024-03-06 10:45:00", "2024-03-07 09:00:00"},
+		{"@hourly", "2024-03-06 23:59:59", "2024-03-07 00:00:00"},
+		{"@daily", "2024-12-31 12:00:00", "2025-01-01 00:00:00"},
+		{"0 9 * * mon-fri", "2024-03-08 17:00:00", "2024-03-11 09:00:00"},
+		{"0 0 * * 7", "2024-03-06 00:00:00", "2024-03-10 00:00:00"},
+		{"0 0 1 jan *", "2024-03-06 00:00:00", "2025-01-01 00:00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		// A restricted day of month and day of week match if either does
+		{"0 0 13 * fri", "2024-09-01 00:00:00", "2024-09-06 00:00:00"},
+		{"0 0 13 * fri", "2024-09-07 00:00:00", "2024-09-13 00:00:00"},
+		{"0 0 31 2 *", "2024-01-01 00:00:00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" from "+tt.from, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron: %v", err)
+			}
+			got := schedule.next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("next = %s, want never", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("next = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryRequest describes an outbound webhook to send
+type DeliveryRequest struct {
+	Target      string            `json:"target"`
+	Method      string            `json:"method,omitempty"` // default POST
+	Headers     map[string]string `json:"headers,omitempty"`
+	Payload     json.RawMessage   `json:"payload,omitempty"`     // JSON body
+	Body        string            `json:"body,omitempty"`        // raw body, used when payload is absent
+	ContentType string            `json:"contentType,omitempty"` // default application/json for payload
+	Signing     *SigningConfig    `json:"signing,omitempty"`
+	Retry       RetryPolicy       `json:"retry"`
+}
+
+// RetryPolicy controls how failed attempts (transport errors and non-2xx answers) are retried
+type RetryPolicy struct {
+	MaxAttempts         int       `json:"maxAttempts,omitempty"`         // including the first attempt (default 5)
+	Strategy            string    `json:"strategy,omitempty"`            // exponential (default), fixed, jitter or schedule
+	InitialDelaySeconds float64   `json:"initialDelaySeconds,omitempty"` // default 1
+	Multiplier          float64   `json:"multiplier,omitempty"`          // exponential growth factor (default 2)
+	MaxDelaySeconds     float64   `json:"maxDelaySeconds,omitempty"`     // cap on a single delay (default 300)
+	ScheduleSeconds     []float64 `json:"scheduleSeconds,omitempty"`     // explicit delays for the schedule strategy
+}
+
+// Delivery is one outbound webhook and the log of its attempts
+type Delivery struct {
+	ID            string            `json:"id"`
+	Source        string            `json:"source"` // api, or the schedule/emulator that created it
+	Status        string            `json:"status"` // pending, retrying, succeeded, failed or cancelled
+	Request       DeliveryRequest   `json:"request"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	NextAttemptAt *time.Time        `json:"nextAttemptAt,omitempty"`
+	CompletedAt   *time.Time        `json:"completedAt,omitempty"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+
+	body   []byte
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// DeliveryAttempt is one try at sending a delivery
+type DeliveryAttempt struct {
+	Number          int                 `json:"number"`
+	Timestamp       time.Time           `json:"timestamp"`
+	RequestHeaders  map[string][]string `json:"requestHeaders"`
+	Status          int                 `json:"status,omitempty"`
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string              `json:"responseBody,omitempty"`
+	LatencyMs       int64               `json:"latencyMs"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// Keep only the most recent deliveries, like the request history
+const maxDeliveries = 100
+
+var (
+	deliveries     []*Delivery
+	deliveriesMux  sync.RWMutex
+	deliveryClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+func (p RetryPolicy) validate() error {
+	switch p.Strategy {
+	case "", "exponential", "fixed", "jitter":
+	case "schedule":
+		if len(p.ScheduleSeconds) == 0 {
+			return fmt.Errorf("schedule strategy needs scheduleSeconds")
+		}
+	default:
+		return fmt.Errorf("unknown retry strategy %q", p.Strategy)
+	}
+	return nil
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 5
+}
+
+// delay returns how long to wait after the given failed attempt (1-based)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	initial := p.InitialDelaySeconds
+	if initial <= 0 {
+		initial = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	maxDelay := p.MaxDelaySeconds
+	if maxDelay <= 0 {
+		maxDelay = 300
+	}
+
+	var seconds float64
+	switch p.Strategy {
+	case "fixed":
+		seconds = initial
+	case "schedule":
+		index := attempt - 1
+		if index >= len(p.ScheduleSeconds) {
+			index = len(p.ScheduleSeconds) - 1
+		}
+		return time.Duration(p.ScheduleSeconds[index] * float64(time.Second))
+	default:
+		seconds = math.Min(initial*math.Pow(multiplier, float64(attempt-1)), maxDelay)
+		if p.Strategy == "jitter" {
+			// Full jitter: anywhere between zero and the exponential delay
+			seconds = rand.Float64() * seconds
+		}
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (req *DeliveryRequest) validate() error {
+	u, err := url.Parse(req.Target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid target URL %q", req.Target)
+	}
+	if req.Signing != nil {
+		if err := req.Signing.validate(); err != nil {
+			return err
+		}
+	}
+	return req.Retry.validate()
+}
+
+// startDelivery records a delivery and sends it in the background. body overrides
+// the request's payload/body when set, e.g. for generated multipart content.
+func startDelivery(req DeliveryRequest, body []byte, source string) (*Delivery, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if req.Method == "" {
+		req.Method = "POST"
+	}
+	if body == nil {
+		if len(req.Payload) > 0 {
+			body = req.Payload
+			if req.ContentType == "" {
+				req.ContentType = "application/json"
+			}
+		} else {
+			body = []byte(req.Body)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	delivery := &Delivery{
+		ID:        fmt.Sprintf("dlv-%d", time.Now().UnixNano()),
+		Source:    source,
+		Status:    "pending",
+		Request:   req,
+		CreatedAt: time.Now(),
+		Attempts:  []DeliveryAttempt{},
+		body:      body,
+		cancel:    cancel,
+	}
+
+	deliveriesMux.Lock()
+	deliveries = append([]*Delivery{delivery}, deliveries...)
+	if len(deliveries) > maxDeliveries {
+		deliveries = deliveries[:maxDeliveries]
+	}
+	deliveriesMux.Unlock()
+
+	log.Printf("Delivery %s (%s): %s %s", delivery.ID, source, req.Method, req.Target)
+	go delivery.run(ctx)
+	return delivery, nil
+}
+
+func (d *Delivery) run(ctx context.Context) {
+	maxAttempts := d.Request.Retry.maxAttempts()
+
+	for number := 1; number <= maxAttempts; number++ {
+		attempt := d.attempt(ctx, number)
+
+		d.mu.Lock()
+		d.Attempts = append(d.Attempts, attempt)
+		succeeded := attempt.Error == "" && attempt.Status >= 200 && attempt.Status < 300
+		if succeeded || number == maxAttempts || ctx.Err() != nil {
+			now := time.Now()
+			d.CompletedAt = &now
+			d.NextAttemptAt = nil
+			switch {
+			case succeeded:
+				d.Status = "succeeded"
+			case ctx.Err() != nil:
+				d.Status = "cancelled"
+			default:
+				d.Status = "failed"
+			}
+			d.mu.Unlock()
+			log.Printf("Delivery %s %s after %d attempt(s)", d.ID, d.Status, number)
+			d.cancel()
+			return
+		}
+
+		wait := d.Request.Retry.delay(number)
+		next := time.Now().Add(wait)
+		d.Status = "retrying"
+		d.NextAttemptAt = &next
+		d.mu.Unlock()
+		log.Printf("Delivery %s attempt %d failed (%d %s), retrying in %s", d.ID, number, attempt.Status, attempt.Error, wait)
+
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			now := time.Now()
+			d.Status = "cancelled"
+			d.CompletedAt = &now
+			d.NextAttemptAt = nil
+			d.mu.Unlock()
+			log.Printf("Delivery %s cancelled", d.ID)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (d *Delivery) attempt(ctx context.Context, number int) DeliveryAttempt {
+	attempt := DeliveryAttempt{Number: number, Timestamp: time.Now()}
+
+	outbound, err := http.NewRequestWithContext(ctx, d.Request.Method, d.Request.Target, bytes.NewReader(d.body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	outbound.Header.Set("User-Agent", "webhook-test-server/1.0")
+	if d.Request.ContentType != "" {
+		outbound.Header.Set("Content-Type", d.Request.ContentType)
+	}
+	for name, value := range d.Request.Headers {
+		outbound.Header.Set(name, value)
+	}
+	// Sign on every attempt so timestamped schemes stay within tolerance
+	if d.Request.Signing != nil {
+		if err := signRequest(d.Request.Signing, outbound.Header, d.body, d.ID, attempt.Timestamp); err != nil {
+			attempt.Error = err.Error()
+			return attempt
+		}
+	}
+	attempt.RequestHeaders = outbound.Header.Clone()
+
+	start := time.Now()
+	resp, err := deliveryClient.Do(outbound)
+	if err != nil {
+		attempt.LatencyMs = time.Since(start).Milliseconds()
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponseBody))
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = fmt.Sprintf("reading response: %v", err)
+	}
+	attempt.Status = resp.StatusCode
+	attempt.ResponseHeaders = resp.Header
+	attempt.ResponseBody = string(body)
+	return attempt
+}
+
+// snapshot copies the delivery under its lock for encoding
+func (d *Delivery) snapshot() Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Delivery{
+		ID:            d.ID,
+		Source:        d.Source,
+		Status:        d.Status,
+		Request:       d.Request,
+		CreatedAt:     d.CreatedAt,
+		NextAttemptAt: d.NextAttemptAt,
+		CompletedAt:   d.CompletedAt,
+		Attempts:      append([]DeliveryAttempt{}, d.Attempts...),
+	}
+}
+
+func (d *Delivery) matches(status, source string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return (status == "" || d.Status == status) && (source == "" || d.Source == source)
+}
+
+func findDelivery(id string) *Delivery {
+	deliveriesMux.RLock()
+	defer deliveriesMux.RUnlock()
+	for _, delivery := range deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+// handleDeliveries serves /api/deliveries and /api/deliveries/{id}[/attempts|/cancel]
+func handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/deliveries"), "/")
+	if path == "" {
+		switch r.Method {
+		case "GET":
+			status := r.URL.Query().Get("status")
+			source := r.URL.Query().Get("source")
+
+			deliveriesMux.RLock()
+			list := []Delivery{}
+			for _, delivery := range deliveries {
+				if delivery.matches(status, source) {
+					list = append(list, delivery.snapshot())
+				}
+			}
+			deliveriesMux.RUnlock()
+			writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": list, "count": len(list)})
+
+		case "POST":
+			var req DeliveryRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid delivery JSON", http.StatusBadRequest)
+				return
+			}
+			delivery, err := startDelivery(req, nil, "api")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusAccepted, delivery.snapshot())
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, action, _ := strings.Cut(path, "/")
+	delivery := findDelivery(id)
+	if delivery == nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, delivery.snapshot())
+	case action == "attempts" && r.Method == "GET":
+		attempts := delivery.snapshot().Attempts
+		writeJSON(w, http.StatusOK, map[string]interface{}{"attempts": attempts, "count": len(attempts)})
+	case (action == "cancel" && r.Method == "POST") || (action == "" && r.Method == "DELETE"):
+		delivery.cancel()
+		writeJSON(w, http.StatusOK, delivery.snapshot())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ThoughtSpotEmulation builds scheduled-report notifications the way ThoughtSpot
+// sends them: a JSON part followed by the report files
+type ThoughtSpotEmulation struct {
+	ReportName   string         `json:"reportName,omitempty"`
+	PinboardID   string         `json:"pinboardId,omitempty"`
+	PinboardName string         `json:"pinboardName,omitempty"`
+	Recipients   []string       `json:"recipients,omitempty"`  // email addresses
+	Timezone     string         `json:"timezone,omitempty"`    // reported in scheduleInfo
+	Attachments  []EmulatedFile `json:"attachments,omitempty"` // default: a sample PDF report
+	Format       string         `json:"format,omitempty"`      // form-data (default) or mixed
+}
+
+// EmulatedFile is an attachment given inline as base64 or taken from file storage
+type EmulatedFile struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     string `json:"content,omitempty"`    // base64
+	StoredFile  string `json:"storedFile,omitempty"` // name of a captured file
+}
+
+// thoughtSpotSend is the body of POST /api/emulators/thoughtspot
+type thoughtSpotSend struct {
+	Target  string            `json:"target"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Signing *SigningConfig    `json:"signing,omitempty"`
+	Retry   RetryPolicy       `json:"retry"`
+	ThoughtSpotEmulation
+}
+
+func (emu *ThoughtSpotEmulation) validate() error {
+	switch emu.Format {
+	case "", "form-data", "mixed":
+	default:
+		return fmt.Errorf("thoughtspot format must be form-data or mixed")
+	}
+	for _, file := range emu.Attachments {
+		if _, err := file.content(); err != nil {
+			return err
+		}
+		if file.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(file.ContentType); err != nil {
+				return fmt.Errorf("attachment %q: invalid contentType %q", file.FileName, file.ContentType)
+			}
+		}
+	}
+	return nil
+}
+
+func (file EmulatedFile) content() ([]byte, error) {
+	if file.StoredFile != "" {
+		fileStorageMux.RLock()
+		content, exists := fileStorage[file.StoredFile]
+		fileStorageMux.RUnlock()
+		if !exists {
+			return nil, fmt.Errorf("stored file %q not found", file.StoredFile)
+		}
+		return content, nil
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return nil, fmt.Errorf("attachment %q: invalid base64 content", file.FileName)
+	}
+	return content, nil
+}
+
+// build returns the multipart body and its content type. schedule, when set,
+// supplies the schedule ID, description and next run time.
+func (emu *ThoughtSpotEmulation) build(now time.Time, schedule *ScheduleRun) ([]byte, string, error) {
+	nextRun := now.AddDate(0, 1, 0)
+	if schedule != nil {
+		nextRun = schedule.NextRunTime
+	}
+	data := sampleThoughtSpotData(now, nextRun)
+	notification := &data.ScheduledReportWebhookNotification
+
+	notification.DeliveryInfo.DeliveryID = fmt.Sprintf("delivery-%d", now.UnixNano())
+	if emu.ReportName != "" {
+		notification.ReportName = emu.ReportName
+	}
+	if emu.PinboardID != "" {
+		notification.ReportMetadata.PinboardID = emu.PinboardID
+		notification.ReportMetadata.ReportURL = "http://thoughtspot.company.com/?utm_source=scheduled_report&utm_medium=webhook/#/pinboard/" + emu.PinboardID
+	}
+	if emu.PinboardName != "" {
+		notification.ReportMetadata.PinboardName = emu.PinboardName
+	}
+	if emu.Timezone != "" {
+		notification.ScheduleInfo.Timezone = emu.Timezone
+	}
+	if schedule != nil {
+		notification.ScheduleInfo.ScheduleID = schedule.Schedule
+		notification.ScheduleInfo.ScheduleString = schedule.ScheduleString
+		notification.ScheduleInfo.Timezone = schedule.RunTime.Location().String()
+	}
+	if len(emu.Recipients) > 0 {
+		data.Users = nil
+		for i, email := range emu.Recipients {
+			name, _, _ := strings.Cut(email, "@")
+			data.Users = append(data.Users, ThoughtSpotUser{
+				DisplayName: name,
+				Email:       email,
+				UserID:      fmt.Sprintf("user-%d", i+1),
+			})
+		}
+		notification.DeliveryInfo.RecipientCount = len(emu.Recipients)
+	}
+
+	files := append([]EmulatedFile(nil), emu.Attachments...)
+	if len(files) == 0 {
+		files = []EmulatedFile{{
+			FileName:    fmt.Sprintf("%s_%s.pdf", strings.ReplaceAll(notification.ReportName, " ", "_"), now.Format("2006-01")),
+			ContentType: "application/pdf",
+			Content:     base64.StdEncoding.EncodeToString([]byte(sampleThoughtSpotPDF)),
+		}}
+	}
+
+	contents := make([][]byte, len(files))
+	notification.Attachments = nil
+	for i, file := range files {
+		content, err := file.content()
+		if err != nil {
+			return nil, "", err
+		}
+		if file.ContentType == "" {
+			files[i].ContentType = "application/octet-stream"
+		}
+		contents[i] = content
+		checksum := sha256.Sum256(content)
+		notification.Attachments = append(notification.Attachments, ThoughtSpotAttachment{
+			AttachmentID: fmt.Sprintf("att-%03d", i+1),
+			FileName:     file.FileName,
+			FileSize:     len(content),
+			ContentType:  files[i].ContentType,
+			Disposition:  "attachment",
+			Checksum:     "sha256:" + hex.EncodeToString(checksum[:]),
+			PartNumber:   i + 2,
+		})
+	}
+
+	jsonBytes, err := json.MarshalIndent(map[string]interface{}{"data": data}, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	mixed := emu.Format == "mixed"
+
+	// Part 1: JSON metadata
+	jsonHeader := textproto.MIMEHeader{"Content-Type": {"application/json"}}
+	if mixed {
+		jsonHeader.Set("Content-Disposition", "inline")
+	} else {
+		jsonHeader.Set("Content-Disposition", `form-data; name="data"`)
+	}
+	part, err := writer.CreatePart(jsonHeader)
+	if err != nil {
+		return nil, "", err
+	}
+	part.Write(jsonBytes)
+
+	// Remaining parts: one per attachment
+	for i, file := range files {
+		header := textproto.MIMEHeader{"Content-Type": {files[i].ContentType}}
+		if mixed {
+			header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename=%q`, file.FileName))
+		} else {
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachment"; filename=%q`, file.FileName))
+		}
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(contents[i])
+	}
+	writer.Close()
+
+	contentType := writer.FormDataContentType()
+	if mixed {
+		contentType = fmt.Sprintf(`multipart/mixed; boundary="%s"`, writer.Boundary())
+	}
+	return body.Bytes(), contentType, nil
+}
+
+// handleEmulators serves POST /api/emulators/{provider}, which sends one
+// provider-style webhook to a target as a delivery
+func handleEmulators(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	provider := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/emulators"), "/")
+	if r.Method == "GET" && provider == "github" {
+		events := gitHubEvents()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"events": events, "count": len(events)})
+		return
+	}
+	if r.Method == "GET" && provider == "stripe" {
+		objects := stripeObjects()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"objects": objects, "count": len(objects)})
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var delivery *Delivery
+	var err error
+
+	switch provider {
+	case "thoughtspot":
+		var send thoughtSpotSend
+		if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+			http.Error(w, "Invalid emulator JSON", http.StatusBadRequest)
+			return
+		}
+		if err := send.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, contentType, buildErr := send.build(time.Now(), nil)
+		if buildErr != nil {
+			http.Error(w, buildErr.Error(), http.StatusBadRequest)
+			return
+		}
+		delivery, err = startDelivery(DeliveryRequest{
+			Target:      send.Target,
+			Headers:     send.Headers,
+			ContentType: contentType,
+			Signing:     send.Signing,
+			Retry:       send.Retry,
+		}, body, "emulator:thoughtspot")
+
+	case "github":
+		var send gitHubSend
+		if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+			http.Error(w, "Invalid emulator JSON", http.StatusBadRequest)
+			return
+		}
+		body, headers, buildErr := send.build(send.Target, time.Now())
+		if buildErr != nil {
+			http.Error(w, buildErr.Error(), http.StatusBadRequest)
+			return
+		}
+		for name, value := range send.Headers {
+			headers[name] = value
+		}
+		request := DeliveryRequest{
+			Target:      send.Target,
+			Headers:     headers,
+			ContentType: "application/json",
+			Retry:       send.Retry,
+		}
+		if send.Secret != "" {
+			request.Signing = &SigningConfig{Scheme: "github", Secret: send.Secret}
+		}
+		delivery, err = startDelivery(request, body, "emulator:github")
+
+	case "stripe":
+		var send stripeSend
+		if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+			http.Error(w, "Invalid emulator JSON", http.StatusBadRequest)
+			return
+		}
+		body, eventID, buildErr := send.build(time.Now())
+		if buildErr != nil {
+			http.Error(w, buildErr.Error(), http.StatusBadRequest)
+			return
+		}
+		headers := map[string]string{
+			"User-Agent":    "Stripe/1.0 (+https://stripe.com/docs/webhooks)",
+			"Cache-Control": "no-cache",
+		}
+		for name, value := range send.Headers {
+			headers[name] = value
+		}
+		request := DeliveryRequest{
+			Target:      send.Target,
+			Headers:     headers,
+			ContentType: "application/json; charset=utf-8",
+			Retry:       send.retryPolicy(),
+		}
+		if send.Retry != nil {
+			request.Retry = *send.Retry
+		}
+		if send.Secret != "" {
+			request.Signing = &SigningConfig{Scheme: "stripe", Secret: send.Secret}
+		}
+		delivery, err = startDelivery(request, body, "emulator:stripe")
+		if err == nil {
+			log.Printf("Stripe event %s (%s)", eventID, send.Type)
+		}
+
+	default:
+		http.Error(w, "Unknown emulator", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Emulated %s webhook to %s", provider, delivery.Request.Target)
+	writeJSON(w, http.StatusAccepted, delivery.snapshot())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+	"time"
+)
+
+func TestThoughtSpotEmulationQuotesFileNames(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte("report"))
+
+	tests := []struct {
+		name     string
+		format   string
+		fileName string
+		want     string
+	}{
+		{"plain", "form-data", "Q3 report.pdf", "Q3 report.pdf"},
+		{"quote", "form-data", `say "hi".pdf`, `say "hi".pdf`},
+		{"header injection", "form-data", "a.pdf\r\nX-Injected: 1", `a.pdf\r\nX-Injected: 1`},
+		{"mixed with quote", "mixed", `say "hi".pdf`, `say "hi".pdf`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emu := ThoughtSpotEmulation{
+				Format:      tt.format,
+				Attachments: []EmulatedFile{{FileName: tt.fileName, ContentType: "application/pdf", Content: content}},
+			}
+			if err := emu.validate(); err != nil {
+				t.Fatal(err)
+			}
+			body, contentType, err := emu.build(time.Now(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, params, err := mime.ParseMediaType(contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+			var parts []*multipart.Part
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, part)
+			}
+
+			if len(parts) != 2 {
+				t.Fatalf("got %d parts, want the JSON part and one attachment", len(parts))
+			}
+			attachment := parts[1]
+			if attachment.Header.Get("X-Injected") != "" {
+				t.Error("file name injected a part header")
+			}
+			_, dispositionParams, err := mime.ParseMediaType(attachment.Header.Get("Content-Disposition"))
+			if err != nil {
+				t.Fatalf("Content-Disposition %q: %v", attachment.Header.Get("Content-Disposition"), err)
+			}
+			if dispositionParams["filename"] != tt.want {
+				t.Errorf("filename = %q, want %q", dispositionParams["filename"], tt.want)
+			}
+		})
+	}
+}
+
+func TestThoughtSpotEmulationRejectsBadContentType(t *testing.T) {
+	emu := ThoughtSpotEmulation{Attachments: []EmulatedFile{{
+		FileName:    "a.pdf",
+		ContentType: "application/pdf\r\nX-Injected: 1",
+		Content:     base64.StdEncoding.EncodeToString([]byte("report")),
+	}}}
+	if err := emu.validate(); err == nil {
+		t.Error("content type with a line break accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncodingInfo records how a Content-Encoding body was decoded
+type EncodingInfo struct {
+	ContentEncoding  string `json:"contentEncoding"`
+	CompressedSize   int    `json:"compressedSize"`
+	DecompressedSize int    `json:"decompressedSize,omitempty"`
+	Error            string `json:"error,omitempty"` // body was kept as received
+}
+
+// Zip-bomb guard: a body may expand to maxDecompressionRatio times its compressed
+// size, but always to minDecompressionAllowance and never past maxDecompressedSize
+const (
+	maxDecompressionRatio     = 100
+	minDecompressionAllowance = 1 << 20
+	maxDecompressedSize       = 32 << 20
+)
+
+const flagDecompressionLimit = "decompression-limit"
+
+// flagUnauthenticated marks a capture stored before its credentials were checked
+const flagUnauthenticated = "unauthenticated"
+
+var errDecompressionLimit = errors.New("decompressed body exceeds the size limit")
+
+// decoded reports whether the stored body is the decompressed one
+func (info *EncodingInfo) decoded() bool {
+	return info != nil && info.Error == ""
+}
+
+// decodeContentEncoding undoes the codings listed in Content-Encoding. It returns the
+// body unchanged when there is nothing to do or it cannot be decoded.
+func decodeContentEncoding(contentEncoding string, body []byte) ([]byte, *EncodingInfo, error) {
+	var codings []string
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) == 0 {
+		return body, nil, nil
+	}
+
+	info := &EncodingInfo{ContentEncoding: contentEncoding, CompressedSize: len(body)}
+	limit := len(body) * maxDecompressionRatio
+	if limit < minDecompressionAllowance {
+		limit = minDecompressionAllowance
+	}
+	if limit > maxDecompressedSize {
+		limit = maxDecompressedSize
+	}
+
+	// Codings are listed in the order they were applied
+	decoded := body
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		decoded, err = decompress(codings[i], decoded, limit)
+		if err != nil {
+			info.Error = fmt.Sprintf("%s: %v", codings[i], err)
+			return body, info, err
+		}
+	}
+	info.DecompressedSize = len(decoded)
+	return decoded, info, nil
+}
+
+func decompress(coding string, data []byte, limit int) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch coding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		// deflate is meant to be zlib-wrapped, but some senders use raw DEFLATE
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(data)), nil
+		}
+	case "br":
+		return nil, fmt.Errorf("brotli is not supported")
+	default:
+		return nil, fmt.Errorf("unsupported content coding")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > limit {
+		return nil, errDecompressionLimit
+	}
+	return decoded, nil
+}
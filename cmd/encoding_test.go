@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func compressWith(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch coding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "zlib":
+		writer = zlib.NewWriter(&buf)
+	case "flate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	payload := []byte(`{"event":"compressed","items":[1,2,3]}`)
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		want            []byte
+		wantInfo        bool
+		wantErr         bool
+	}{
+		{"no encoding", "", payload, payload, false, false},
+		{"identity", "identity", payload, payload, false, false},
+		{"gzip", "gzip", compressWith(t, "gzip", payload), payload, true, false},
+		{"x-gzip, upper case", "X-GZIP", compressWith(t, "gzip", payload), payload, true, false},
+		{"zlib deflate", "deflate", compressWith(t, "zlib", payload), payload, true, false},
+		{"raw deflate", "deflate", compressWith(t, "flate", payload), payload, true, false},
+		{"chain in applied order", "gzip, deflate", compressWith(t, "zlib", compressWith(t, "gzip", payload)), payload, true, false},
+		{"brotli", "br", payload, payload, true, true},
+		{"unknown coding", "compress", payload, payload, true, true},
+		{"corrupt gzip", "gzip", []byte("not gzip"), []byte("not gzip"), true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, info, err := decodeContentEncoding(tt.contentEncoding, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if (info != nil) != tt.wantInfo {
+				t.Fatalf("info = %+v, wantInfo %v", info, tt.wantInfo)
+			}
+			if info == nil {
+				return
+			}
+			if info.CompressedSize != len(tt.body) {
+				t.Errorf("compressedSize = %d, want %d", info.CompressedSize, len(tt.body))
+			}
+			if info.decoded() == tt.wantErr {
+				t.Errorf("decoded() = %v with error %q", info.decoded(), info.Error)
+			}
+			if !tt.wantErr && info.DecompressedSize != len(payload) {
+				t.Errorf("decompressedSize = %d, want %d", info.DecompressedSize, len(payload))
+			}
+		})
+	}
+}
+
+func TestDecodeContentEncodingLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		// Zeros compress far beyond the ratio, so the minimum allowance applies
+		{"at the allowance", minDecompressionAllowance, false},
+		{"one byte over", minDecompressionAllowance + 1, true},
+		{"zip bomb", 64 << 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := compressWith(t, "gzip", make([]byte, tt.size))
+			got, info, err := decodeContentEncoding("gzip", body)
+			if !tt.wantErr {
+				if err != nil || len(got) != tt.size {
+					t.Fatalf("got %d bytes, err %v; want %d bytes", len(got), err, tt.size)
+				}
+				return
+			}
+			if !errors.Is(err, errDecompressionLimit) {
+				t.Fatalf("err = %v, want errDecompressionLimit", err)
+			}
+			if !bytes.Equal(got, body) {
+				t.Error("body was not kept as received")
+			}
+			if info == nil || info.decoded() {
+				t.Errorf("info = %+v, want a decoding error", info)
+			}
+		})
+	}
+}
+
+func TestOversizedBodyIsCapturedAsUnauthenticated(t *testing.T) {
+	withHistory(t, nil)
+
+	body := compressWith(t, "gzip", make([]byte, 2*minDecompressionAllowance))
+	r := httptest.NewRequest("POST", "/webhook/bomb", bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handleWebhook(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+	requestsMux.RLock()
+	captured := requests[0]
+	requestsMux.RUnlock()
+	if !hasFlag(captured, flagDecompressionLimit) || !hasFlag(captured, flagUnauthenticated) {
+		t.Errorf("flags = %v", captured.Flags)
+	}
+	if !bytes.Equal(captured.RawBody, body) {
+		t.Error("compressed body not kept as received")
+	}
+}
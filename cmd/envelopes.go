@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvelopeConfig unwraps cloud push envelopes so the capture shows the inner payload
+type EnvelopeConfig struct {
+	Type        string `json:"type,omitempty"`        // sns, pubsub, eventgrid or auto (default)
+	SNSCertFile string `json:"snsCertFile,omitempty"` // PEM certificate the SNS messages are signed with
+	AutoConfirm bool   `json:"autoConfirm,omitempty"` // call SubscribeURL for SNS SubscriptionConfirmation
+	Enforce     bool   `json:"enforce,omitempty"`     // reject SNS messages with an invalid signature
+
+	// Hosts SubscribeURL may point at, e.g. "sns.us-east-1.amazonaws.com" or
+	// "*.amazonaws.com". autoConfirm needs these or snsCertFile.
+	ConfirmHosts []string `json:"confirmHosts,omitempty"`
+}
+
+// EnvelopeInfo is stored on the capture
+type EnvelopeInfo struct {
+	Type         string                `json:"type"`                  // sns, pubsub or eventgrid
+	MessageType  string                `json:"messageType,omitempty"` // SNS Type or Event Grid eventType
+	MessageID    string                `json:"messageId,omitempty"`
+	Source       string                `json:"source,omitempty"` // topic ARN, Pub/Sub subscription or Event Grid topic
+	Subject      string                `json:"subject,omitempty"`
+	Attributes   map[string]string     `json:"attributes,omitempty"`
+	Payload      interface{}           `json:"payload,omitempty"` // decoded inner message
+	Signature    *SignatureResult      `json:"signature,omitempty"`
+	Confirmation *EnvelopeConfirmation `json:"confirmation,omitempty"`
+
+	subscribeURL   string
+	validationCode string
+}
+
+// EnvelopeConfirmation records the automatic SNS subscription confirmation
+type EnvelopeConfirmation struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type snsMessage struct {
+	Type              string `json:"Type"`
+	MessageID         string `json:"MessageId"`
+	Token             string `json:"Token"`
+	TopicArn          string `json:"TopicArn"`
+	Subject           string `json:"Subject"`
+	Message           string `json:"Message"`
+	Timestamp         string `json:"Timestamp"`
+	SignatureVersion  string `json:"SignatureVersion"`
+	Signature         string `json:"Signature"`
+	SigningCertURL    string `json:"SigningCertURL"`
+	SubscribeURL      string `json:"SubscribeURL"`
+	UnsubscribeURL    string `json:"UnsubscribeURL"`
+	MessageAttributes map[string]struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+type pubSubPush struct {
+	Message *struct {
+		Data        string            `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		MessageID   string            `json:"messageId"`
+		PublishTime string            `json:"publishTime"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+type eventGridEvent struct {
+	ID          string          `json:"id"`
+	Topic       string          `json:"topic"`
+	Subject     string          `json:"subject"`
+	EventType   string          `json:"eventType"`
+	EventTime   string          `json:"eventTime"`
+	Data        json.RawMessage `json:"data"`
+	DataVersion string          `json:"dataVersion"`
+}
+
+const eventGridValidationEvent = "Microsoft.EventGrid.SubscriptionValidationEvent"
+
+func (cfg *EnvelopeConfig) validate() error {
+	switch cfg.Type {
+	case "", "auto", "sns", "pubsub", "eventgrid":
+	default:
+		return fmt.Errorf("unknown envelope type %q", cfg.Type)
+	}
+	// Without a signature check or a host allowlist anyone could make the server fetch any URL
+	if cfg.AutoConfirm && cfg.SNSCertFile == "" && len(cfg.ConfirmHosts) == 0 {
+		return fmt.Errorf("envelope autoConfirm needs snsCertFile or confirmHosts")
+	}
+	return nil
+}
+
+// confirmRefusal explains why a SubscriptionConfirmation may not be confirmed, or
+// returns "" when it may: its signature must verify when snsCertFile is set, and
+// SubscribeURL must be on confirmHosts when that is set
+func (cfg *EnvelopeConfig) confirmRefusal(envelope *EnvelopeInfo) string {
+	if cfg.SNSCertFile != "" && (envelope.Signature == nil || !envelope.Signature.Valid) {
+		return "signature did not verify"
+	}
+	if len(cfg.ConfirmHosts) == 0 {
+		return ""
+	}
+	parsed, err := url.Parse(envelope.subscribeURL)
+	if err != nil || parsed.Hostname() == "" {
+		return "SubscribeURL is not a valid URL"
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range cfg.ConfirmHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("host %s is not in confirmHosts", host)
+}
+
+// decodeEnvelope recognises and unwraps the envelope, or returns nil if the body is not one
+func decodeEnvelope(cfg *EnvelopeConfig, r *http.Request, body []byte) *EnvelopeInfo {
+	kind := cfg.Type
+	if kind == "" || kind == "auto" {
+		kind = detectEnvelope(r, body)
+	}
+
+	switch kind {
+	case "sns":
+		return decodeSNS(cfg, body)
+	case "pubsub":
+		return decodePubSub(body)
+	case "eventgrid":
+		return decodeEventGrid(body)
+	}
+	return nil
+}
+
+func detectEnvelope(r *http.Request, body []byte) string {
+	switch {
+	case r.Header.Get("X-Amz-Sns-Message-Type") != "":
+		return "sns"
+	case r.Header.Get("Aeg-Event-Type") != "":
+		return "eventgrid"
+	}
+
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(body, &probe) == nil {
+		if probe["TopicArn"] != nil && probe["Type"] != nil {
+			return "sns"
+		}
+		if probe["message"] != nil && probe["subscription"] != nil {
+			return "pubsub"
+		}
+		return ""
+	}
+	var events []map[string]json.RawMessage
+	if json.Unmarshal(body, &events) == nil && len(events) > 0 && events[0]["eventType"] != nil && events[0]["dataVersion"] != nil {
+		return "eventgrid"
+	}
+	return ""
+}
+
+func decodeSNS(cfg *EnvelopeConfig, body []byte) *EnvelopeInfo {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil || message.Type == "" {
+		return nil
+	}
+
+	info := &EnvelopeInfo{
+		Type:         "sns",
+		MessageType:  message.Type,
+		MessageID:    message.MessageID,
+		Source:       message.TopicArn,
+		Subject:      message.Subject,
+		Payload:      decodeInnerPayload([]byte(message.Message)),
+		subscribeURL: message.SubscribeURL,
+	}
+	if len(message.MessageAttributes) > 0 {
+		info.Attributes = make(map[string]string)
+		for name, attribute := range message.MessageAttributes {
+			info.Attributes[name] = attribute.Value
+		}
+	}
+
+	if cfg.SNSCertFile != "" {
+		result := SignatureResult{Provider: "sns", MessageID: message.MessageID}
+		if err := verifySNSSignature(cfg.SNSCertFile, message); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Valid = true
+		}
+		info.Signature = &result
+	}
+	return info
+}
+
+// verifySNSSignature checks the message against a local copy of the signing
+// certificate; SigningCertURL is never fetched
+func verifySNSSignature(certFile string, message snsMessage) error {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("reading certificate: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("no PEM block in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parsing certificate: %v", err)
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate does not hold an RSA key")
+	}
+
+	var h hash.Hash
+	var algorithm crypto.Hash
+	switch message.SignatureVersion {
+	case "1":
+		h, algorithm = sha1.New(), crypto.SHA1
+	case "2":
+		h, algorithm = sha256.New(), crypto.SHA256
+	default:
+		return fmt.Errorf("unsupported SignatureVersion %q", message.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64")
+	}
+	h.Write([]byte(snsStringToSign(message)))
+	if err := rsa.VerifyPKCS1v15(publicKey, algorithm, h.Sum(nil), signature); err != nil {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// snsStringToSign builds the canonical "Key\nValue\n" string SNS signs
+func snsStringToSign(message snsMessage) string {
+	fields := [][2]string{{"Message", message.Message}, {"MessageId", message.MessageID}}
+	if message.Type == "Notification" {
+		if message.Subject != "" {
+			fields = append(fields, [2]string{"Subject", message.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", message.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", message.Timestamp})
+	if message.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", message.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", message.TopicArn}, [2]string{"Type", message.Type})
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+func decodePubSub(body []byte) *EnvelopeInfo {
+	var push pubSubPush
+	if err := json.Unmarshal(body, &push); err != nil || push.Message == nil {
+		return nil
+	}
+
+	info := &EnvelopeInfo{
+		Type:       "pubsub",
+		MessageID:  push.Message.MessageID,
+		Source:     push.Subscription,
+		Attributes: push.Message.Attributes,
+	}
+	if data, err := base64.StdEncoding.DecodeString(push.Message.Data); err == nil {
+		info.Payload = decodeInnerPayload(data)
+	} else {
+		info.Payload = push.Message.Data
+	}
+	return info
+}
+
+func decodeEventGrid(body []byte) *EnvelopeInfo {
+	var events []eventGridEvent
+	if err := json.Unmarshal(body, &events); err != nil || len(events) == 0 {
+		return nil
+	}
+
+	first := events[0]
+	info := &EnvelopeInfo{
+		Type:        "eventgrid",
+		MessageType: first.EventType,
+		MessageID:   first.ID,
+		Source:      first.Topic,
+		Subject:     first.Subject,
+	}
+
+	payloads := make([]interface{}, len(events))
+	for i, event := range events {
+		payloads[i] = decodeInnerPayload(event.Data)
+	}
+	if len(payloads) == 1 {
+		info.Payload = payloads[0]
+	} else {
+		info.Payload = payloads
+	}
+
+	if first.EventType == eventGridValidationEvent {
+		var data struct {
+			ValidationCode string `json:"validationCode"`
+		}
+		json.Unmarshal(first.Data, &data)
+		info.validationCode = data.ValidationCode
+	}
+	return info
+}
+
+// decodeInnerPayload returns parsed JSON when the payload is JSON, else the text
+func decodeInnerPayload(data []byte) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err == nil {
+		return parsed
+	}
+	return string(data)
+}
+
+// confirmSNSSubscription visits SubscribeURL and records the outcome on the capture
+func confirmSNSSubscription(requestID, subscribeURL string) {
+	confirmation := EnvelopeConfirmation{URL: subscribeURL}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(subscribeURL)
+	if err != nil {
+		confirmation.Error = err.Error()
+	} else {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		confirmation.Status = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			confirmation.Error = resp.Status
+		}
+	}
+	log.Printf("SNS subscription confirmation for %s: %d %s", requestID, confirmation.Status, confirmation.Error)
+
+	updateRequest(requestID, func(request *WebhookRequest) {
+		if request.Envelope != nil {
+			envelope := *request.Envelope
+			envelope.Confirmation = &confirmation
+			request.Envelope = &envelope
+		}
+	})
+}
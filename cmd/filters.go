@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RequestFilter selects captured requests for /api/requests and bulk replay jobs.
+// Empty fields match everything.
+type RequestFilter struct {
+	IDs         []string `json:"ids,omitempty"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Method      string   `json:"method,omitempty"`
+	ContentType string   `json:"contentType,omitempty"` // prefix match, e.g. "application/json"
+	Since       string   `json:"since,omitempty"`       // RFC3339 time or a duration such as "1h" meaning that long ago
+	Until       string   `json:"until,omitempty"`       // RFC3339 time or a duration
+	Search      string   `json:"search,omitempty"`      // substring of the body
+	Flag        string   `json:"flag,omitempty"`        // e.g. "unauthorized" or "handshake"
+	Provider    string   `json:"provider,omitempty"`    // classified sender, e.g. "github"
+	EventType   string   `json:"eventType,omitempty"`   // classified event type, or the type of any CloudEvent in the capture
+	EventSource string   `json:"eventSource,omitempty"` // CloudEvents source of any event in the capture
+	SOAPAction  string   `json:"soapAction,omitempty"`
+	XMLRoot     string   `json:"xmlRoot,omitempty"` // root element of the XML body, or of the SOAP body
+}
+
+func filterFromQuery(query url.Values) RequestFilter {
+	filter := RequestFilter{
+		Endpoint:    query.Get("endpoint"),
+		Method:      query.Get("method"),
+		ContentType: query.Get("contentType"),
+		Since:       query.Get("since"),
+		Until:       query.Get("until"),
+		Search:      query.Get("search"),
+		Flag:        query.Get("flag"),
+		Provider:    query.Get("provider"),
+		EventType:   query.Get("eventType"),
+		EventSource: query.Get("eventSource"),
+		SOAPAction:  query.Get("soapAction"),
+		XMLRoot:     query.Get("xmlRoot"),
+	}
+	if ids := query.Get("ids"); ids != "" {
+		filter.IDs = strings.Split(ids, ",")
+	}
+	return filter
+}
+
+func (f RequestFilter) validate() error {
+	if _, err := parseFilterTime(f.Since, time.Now()); err != nil {
+		return fmt.Errorf("since: %w", err)
+	}
+	if _, err := parseFilterTime(f.Until, time.Now()); err != nil {
+		return fmt.Errorf("until: %w", err)
+	}
+	return nil
+}
+
+// parseFilterTime accepts an RFC3339 timestamp or a duration counted back from now
+func parseFilterTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 time nor a duration", value)
+	}
+	return now.Add(-d), nil
+}
+
+func (f RequestFilter) matches(request WebhookRequest, now time.Time) bool {
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			if id == request.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Endpoint != "" && request.Endpoint != f.Endpoint {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(request.Method, f.Method) {
+		return false
+	}
+	if f.ContentType != "" && !strings.HasPrefix(request.ContentType, f.ContentType) {
+		return false
+	}
+	if since, _ := parseFilterTime(f.Since, now); !since.IsZero() && request.Timestamp.Before(since) {
+		return false
+	}
+	if until, _ := parseFilterTime(f.Until, now); !until.IsZero() && request.Timestamp.After(until) {
+		return false
+	}
+	if f.Search != "" && !bytes.Contains(requestBodyBytes(request), []byte(f.Search)) {
+		return false
+	}
+	if f.Flag != "" && !hasFlag(request, f.Flag) {
+		return false
+	}
+	if f.Provider != "" && !strings.EqualFold(request.Provider, f.Provider) {
+		return false
+	}
+	if f.EventType != "" || f.EventSource != "" {
+		found := f.EventSource == "" && request.EventType == f.EventType
+		for _, event := range request.CloudEvents {
+			if (f.EventType == "" || event.Type == f.EventType) && (f.EventSource == "" || event.Source == f.EventSource) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.SOAPAction != "" && (request.XML == nil || request.XML.SOAPAction != f.SOAPAction) {
+		return false
+	}
+	if f.XMLRoot != "" && (request.XML == nil || request.XML.BodyRoot != f.XMLRoot) {
+		return false
+	}
+	return true
+}
+
+// filterRequests returns the matching captures, newest first like the history itself
+func filterRequests(filter RequestFilter) []WebhookRequest {
+	now := time.Now()
+
+	requestsMux.RLock()
+	defer requestsMux.RUnlock()
+
+	matched := []WebhookRequest{}
+	for _, request := range requests {
+		if filter.matches(request, now) {
+			matched = append(matched, request)
+		}
+	}
+	return matched
+}
+
+func hasFlag(request WebhookRequest, flag string) bool {
+	for _, f := range request.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func requestBodyBytes(request WebhookRequest) []byte {
+	if request.RawBody != nil {
+		return request.RawBody
+	}
+	data, _ := json.Marshal(request.Body)
+	return data
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// isFormURLEncoded reports whether contentType is application/x-www-form-urlencoded
+func isFormURLEncoded(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// parseFormBody decodes a urlencoded body into the same shape as multipart formData:
+// a single value as a string and a repeated key as a list. Values holding a JSON
+// object or array, such as the payload field of Slack interactions, are parsed.
+func parseFormBody(body []byte) (map[string]interface{}, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	formData := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			formData[key] = formValue(list[0])
+			continue
+		}
+		items := make([]interface{}, len(list))
+		for i, value := range list {
+			items[i] = formValue(value)
+		}
+		formData[key] = items
+	}
+	return formData, nil
+}
+
+func formValue(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var parsed interface{}
+		if json.Unmarshal([]byte(trimmed), &parsed) == nil {
+			return parsed
+		}
+	}
+	return value
+}
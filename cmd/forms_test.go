@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsFormURLEncoded(t *testing.T) {
+	tests := map[string]bool{
+		"application/x-www-form-urlencoded":                true,
+		"application/x-www-form-urlencoded; charset=UTF-8": true,
+		"Application/X-WWW-Form-URLEncoded":                true,
+		"multipart/form-data; boundary=x":                  false,
+		"application/json":                                 false,
+		"":                                                 false,
+	}
+	for contentType, want := range tests {
+		if got := isFormURLEncoded(contentType); got != want {
+			t.Errorf("isFormURLEncoded(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestParseFormBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"empty", "", map[string]interface{}{}, false},
+		{"single values", "team=T1&text=hello+world", map[string]interface{}{"team": "T1", "text": "hello world"}, false},
+		{"repeated key", "tag=a&tag=b", map[string]interface{}{"tag": []interface{}{"a", "b"}}, false},
+		{"percent-encoded", "From=%2B15551234567", map[string]interface{}{"From": "+15551234567"}, false},
+		{
+			"JSON payload field",
+			"payload=%7B%22type%22%3A%22block_actions%22%7D",
+			map[string]interface{}{"payload": map[string]interface{}{"type": "block_actions"}},
+			false,
+		},
+		{"JSON array field", "ids=%5B1%2C2%5D", map[string]interface{}{"ids": []interface{}{float64(1), float64(2)}}, false},
+		{"brace that is not JSON", "text=%7Bnot+json", map[string]interface{}{"text": "{not json"}, false},
+		{"invalid escape", "a=%zz", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFormBody([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormCaptureKeepsRawBody(t *testing.T) {
+	withHistory(t, nil)
+	const body = "command=%2Fdeploy&text=prod"
+
+	r := httptest.NewRequest("POST", "/webhook/slack", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handleWebhook(httptest.NewRecorder(), r)
+
+	requestsMux.RLock()
+	captured := requests[0]
+	requestsMux.RUnlock()
+
+	want := map[string]interface{}{"command": "/deploy", "text": "prod"}
+	if !reflect.DeepEqual(captured.Body, want) {
+		t.Errorf("body = %#v, want %#v", captured.Body, want)
+	}
+	if string(captured.RawBody) != body {
+		t.Errorf("raw body = %q, want %q", captured.RawBody, body)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ForwardConfig relays every capture on an endpoint to one or more upstreams.
+// With several targets the request is sent to all of them in parallel and Respond
+// picks what the sender gets back.
+type ForwardConfig struct {
+	URL            string   `json:"url,omitempty"`            // primary upstream
+	Targets        []string `json:"targets,omitempty"`        // additional fan-out targets
+	Respond        string   `json:"respond,omitempty"`        // primary (default), first or ack
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"` // default 30
+}
+
+// UpstreamResponse is what a forwarding target answered, stored on the capture
+type UpstreamResponse struct {
+	Target    string              `json:"target"`
+	Status    int                 `json:"status,omitempty"`
+	Headers   map[string][]string `json:"headers,omitempty"`
+	Body      string              `json:"body,omitempty"`
+	LatencyMs int64               `json:"latencyMs"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// Upper bound on an upstream body relayed back to the sender
+const maxForwardedResponseBody = 32 << 20
+
+func (cfg *ForwardConfig) validate() error {
+	targets := cfg.targets()
+	if len(targets) == 0 {
+		return fmt.Errorf("forward needs a url or targets")
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid forward target %q", target)
+		}
+	}
+	switch cfg.Respond {
+	case "", "primary", "first", "ack":
+	default:
+		return fmt.Errorf("forward respond must be primary, first or ack")
+	}
+	return nil
+}
+
+// targets lists the primary upstream first
+func (cfg *ForwardConfig) targets() []string {
+	var targets []string
+	if cfg.URL != "" {
+		targets = append(targets, cfg.URL)
+	}
+	return append(targets, cfg.Targets...)
+}
+
+// ForwardEvent is broadcast over /ws as fan-out targets answer after the capture
+// was stored: "forward.result" for each target and "forward.completed" for the last
+type ForwardEvent struct {
+	Event     string             `json:"event"`
+	RequestID string             `json:"requestId"`
+	Upstream  *UpstreamResponse  `json:"upstream,omitempty"`
+	Upstreams []UpstreamResponse `json:"upstreams,omitempty"`
+}
+
+type forwardResult struct {
+	index    int
+	response UpstreamResponse
+	body     []byte
+}
+
+// handleForward sends the capture to every target in parallel and answers the
+// sender as soon as the chosen response is known. Targets still running after
+// that are recorded on the capture, and broadcast, one by one as they finish.
+func handleForward(w http.ResponseWriter, r *http.Request, body []byte, cfg *ForwardConfig, webhookReq WebhookRequest) {
+	targets := cfg.targets()
+	results := make(chan forwardResult, len(targets))
+	for i, target := range targets {
+		go func(index int, target string) {
+			response, upstreamBody := forwardRequest(target, cfg.timeout(), r, body)
+			results <- forwardResult{index, response, upstreamBody}
+		}(i, target)
+	}
+
+	responses := make([]UpstreamResponse, len(targets))
+	pending := len(targets)
+	chosen := -1
+	var chosenBody []byte
+
+	if cfg.Respond != "ack" {
+		for chosen < 0 && pending > 0 {
+			result := <-results
+			pending--
+			responses[result.index] = result.response
+
+			if (cfg.Respond == "first" && result.response.Status != 0) || (cfg.Respond != "first" && result.index == 0) {
+				chosen = result.index
+				chosenBody = result.body
+			}
+		}
+	}
+
+	// record stores the outcomes known so far; finished means every target has answered
+	record := func(responses []UpstreamResponse, finished bool) func(*WebhookRequest) {
+		return func(request *WebhookRequest) {
+			if len(responses) > 1 {
+				request.Upstreams = responses
+			}
+			switch {
+			case chosen >= 0:
+				upstream := responses[chosen]
+				request.Upstream = &upstream
+			case finished && len(responses) == 1:
+				// "ack" answers the sender itself, but the upstream's reply is still kept
+				upstream := responses[0]
+				request.Upstream = &upstream
+			}
+		}
+	}
+
+	record(append([]UpstreamResponse(nil), responses...), pending == 0)(&webhookReq)
+	addRequest(webhookReq)
+
+	if pending > 0 {
+		go func() {
+			for pending > 0 {
+				result := <-results
+				pending--
+				responses[result.index] = result.response
+
+				event := ForwardEvent{Event: "forward.result", RequestID: webhookReq.ID}
+				if pending == 0 {
+					event.Event = "forward.completed"
+				}
+				updateRequest(webhookReq.ID, func(request *WebhookRequest) {
+					record(append([]UpstreamResponse(nil), responses...), pending == 0)(request)
+					event.Upstream = request.Upstream
+					event.Upstreams = request.Upstreams
+				})
+				broadcast(webhookReq.Endpoint, event)
+			}
+			log.Printf("Fan-out for %s finished on all %d targets", webhookReq.ID, len(targets))
+		}()
+	}
+
+	switch {
+	case cfg.Respond == "ack":
+		writeWebhookResponse(w)
+	case chosen >= 0:
+		writeUpstreamResponse(w, responses[chosen], chosenBody)
+	default:
+		// "first" with every target failing
+		writeUpstreamResponse(w, responses[0], nil)
+	}
+}
+
+// forwardRequest sends the received request to target and returns the recorded
+// response together with the full upstream body
+func forwardRequest(target string, timeout time.Duration, r *http.Request, body []byte) (UpstreamResponse, []byte) {
+	result := UpstreamResponse{Target: target}
+
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	if r.URL.RawQuery != "" {
+		if targetURL.RawQuery != "" {
+			targetURL.RawQuery += "&" + r.URL.RawQuery
+		} else {
+			targetURL.RawQuery = r.URL.RawQuery
+		}
+	}
+
+	outbound, err := http.NewRequest(r.Method, targetURL.String(), bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	outbound.Header = r.Header.Clone()
+	for _, name := range hopHeaders {
+		outbound.Header.Del(name)
+	}
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		outbound.Header.Add("X-Forwarded-For", clientIP)
+	}
+	outbound.Header.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		outbound.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		outbound.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		// Relay redirects to the sender instead of following them
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	start := time.Now()
+	resp, err := client.Do(outbound)
+	if err != nil {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Error = err.Error()
+		log.Printf("Forward to %s failed: %v", target, err)
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	upstreamBody, err := io.ReadAll(io.LimitReader(resp.Body, maxForwardedResponseBody))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("reading response: %v", err)
+	}
+
+	result.Status = resp.StatusCode
+	result.Headers = resp.Header
+	recorded := upstreamBody
+	if len(recorded) > maxRecordedResponseBody {
+		recorded = recorded[:maxRecordedResponseBody]
+	}
+	result.Body = string(recorded)
+
+	log.Printf("Forwarded to %s: %d in %dms", target, result.Status, result.LatencyMs)
+	return result, upstreamBody
+}
+
+// writeUpstreamResponse relays an upstream answer, or a 502 if there was none
+func writeUpstreamResponse(w http.ResponseWriter, upstream UpstreamResponse, body []byte) {
+	if upstream.Status == 0 {
+		http.Error(w, "Upstream request failed: "+upstream.Error, http.StatusBadGateway)
+		return
+	}
+
+	for name, values := range upstream.Headers {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	for _, name := range hopHeaders {
+		w.Header().Del(name)
+	}
+	w.WriteHeader(upstream.Status)
+	w.Write(body)
+}
+
+func (cfg *ForwardConfig) timeout() time.Duration {
+	if cfg.TimeoutSeconds > 0 {
+		return time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return 30 * time.Second
+}
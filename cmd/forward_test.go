@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForCapture polls history until check accepts the capture
+func waitForCapture(t *testing.T, id string, check func(WebhookRequest) bool) WebhookRequest {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		request, ok := findRequest(id)
+		if ok && check(request) {
+			return request
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("capture %s never reached the expected state: %+v", id, request)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFanOutRecordsEachTargetAsItFinishes(t *testing.T) {
+	withHistory(t, nil)
+
+	release := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer primary.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer second.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	cfg := &ForwardConfig{URL: primary.URL, Targets: []string{second.URL, slow.URL}}
+	r := httptest.NewRequest("POST", "/webhook/fanout", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	handleForward(w, r, []byte(`{}`), cfg, WebhookRequest{ID: "req-fanout", Endpoint: "fanout", Provider: "test"})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("sender got %d, want the primary's 200", w.Code)
+	}
+
+	// The second target is recorded while the slow one is still running
+	captured := waitForCapture(t, "req-fanout", func(request WebhookRequest) bool {
+		return len(request.Upstreams) == 3 && request.Upstreams[1].Status != 0
+	})
+	if captured.Upstreams[1].Status != http.StatusAccepted || captured.Upstreams[2].Status != 0 {
+		t.Errorf("upstreams = %+v", captured.Upstreams)
+	}
+	if captured.Upstream == nil || captured.Upstream.Status != http.StatusOK {
+		t.Errorf("upstream = %+v, want the primary's response", captured.Upstream)
+	}
+
+	release <- struct{}{}
+	waitForCapture(t, "req-fanout", func(request WebhookRequest) bool {
+		return request.Upstreams[2].Status == http.StatusTeapot
+	})
+}
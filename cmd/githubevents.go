@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// GitHubEmulation describes one GitHub delivery. Payloads are rendered from
+// templates/github/{event}.json (TEMPLATES_DIR overrides the directory), which
+// are re-read on each send so they can be edited while the server runs.
+type GitHubEmulation struct {
+	Event      string                 `json:"event"`                // push, pull_request, issues, issue_comment, workflow_run, release, ping...
+	Action     string                 `json:"action,omitempty"`     // defaults per event, e.g. opened or completed
+	Repository string                 `json:"repository,omitempty"` // owner/name (default octo-org/hello-world)
+	Sender     string                 `json:"sender,omitempty"`     // login (default octocat)
+	Ref        string                 `json:"ref,omitempty"`        // default refs/heads/main
+	Number     int                    `json:"number,omitempty"`     // pull request, issue or run number (default 1)
+	Title      string                 `json:"title,omitempty"`      // commit message, PR or issue title
+	Vars       map[string]interface{} `json:"vars,omitempty"`       // extra values for custom templates
+	Template   string                 `json:"template,omitempty"`   // inline template used instead of the file
+	Secret     string                 `json:"secret,omitempty"`     // webhook secret for X-Hub-Signature-256
+}
+
+// GitHubEventData is what GitHub templates are rendered with
+type GitHubEventData struct {
+	Event          string
+	Action         string
+	DeliveryID     string
+	HookID         int64
+	InstallationID int64
+	Target         string
+	Owner          string
+	Repo           string
+	FullName       string
+	RepoID         int64
+	Sender         string
+	Ref            string
+	Branch         string
+	Before         string // commit SHAs
+	After          string
+	Number         int
+	Title          string
+	Now            time.Time
+	Vars           map[string]interface{}
+}
+
+// gitHubSend is the body of POST /api/emulators/github
+type gitHubSend struct {
+	Target  string            `json:"target"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Retry   RetryPolicy       `json:"retry"`
+	GitHubEmulation
+}
+
+var gitHubDefaultActions = map[string]string{
+	"pull_request":  "opened",
+	"issues":        "opened",
+	"issue_comment": "created",
+	"workflow_run":  "completed",
+	"release":       "published",
+}
+
+func templatesDir() string {
+	if dir := os.Getenv("TEMPLATES_DIR"); dir != "" {
+		return dir
+	}
+	return "templates"
+}
+
+// gitHubEvents lists the events that have a template file
+func gitHubEvents() []string {
+	paths, _ := filepath.Glob(filepath.Join(templatesDir(), "github", "*.json"))
+	events := make([]string, 0, len(paths))
+	for _, path := range paths {
+		events = append(events, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	sort.Strings(events)
+	return events
+}
+
+// build renders the payload and returns it with the GitHub delivery headers
+func (emu *GitHubEmulation) build(target string, now time.Time) ([]byte, map[string]string, error) {
+	if emu.Event == "" {
+		return nil, nil, fmt.Errorf("event is required")
+	}
+	if strings.ContainsAny(emu.Event, `/\.`) {
+		return nil, nil, fmt.Errorf("invalid event name %q", emu.Event)
+	}
+
+	dir := filepath.Join(templatesDir(), "github")
+	tmpl := template.New(emu.Event).Funcs(templateFuncs).Option("missingkey=zero")
+	if common, err := os.ReadFile(filepath.Join(dir, "common.tmpl")); err == nil {
+		if _, err := tmpl.Parse(string(common)); err != nil {
+			return nil, nil, fmt.Errorf("common.tmpl: %w", err)
+		}
+	}
+	source := emu.Template
+	if source == "" {
+		data, err := os.ReadFile(filepath.Join(dir, emu.Event+".json"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("no template for GitHub event %q", emu.Event)
+		}
+		source = string(data)
+	}
+	if _, err := tmpl.Parse(source); err != nil {
+		return nil, nil, fmt.Errorf("template: %w", err)
+	}
+
+	data := GitHubEventData{
+		Event:          emu.Event,
+		Action:         emu.Action,
+		DeliveryID:     newUUID(),
+		HookID:         412345678,
+		InstallationID: 31415926,
+		Target:         target,
+		Owner:          "octo-org",
+		Repo:           "hello-world",
+		RepoID:         91734098,
+		Sender:         emu.Sender,
+		Ref:            emu.Ref,
+		Before:         randomHex(20),
+		After:          randomHex(20),
+		Number:         emu.Number,
+		Title:          emu.Title,
+		Now:            now.UTC(),
+		Vars:           emu.Vars,
+	}
+	if data.Action == "" {
+		data.Action = gitHubDefaultActions[emu.Event]
+	}
+	if emu.Repository != "" {
+		owner, repo, ok := strings.Cut(emu.Repository, "/")
+		if !ok || owner == "" || repo == "" {
+			return nil, nil, fmt.Errorf("repository must be owner/name")
+		}
+		data.Owner, data.Repo = owner, repo
+	}
+	data.FullName = data.Owner + "/" + data.Repo
+	if data.Sender == "" {
+		data.Sender = "octocat"
+	}
+	if data.Ref == "" {
+		data.Ref = "refs/heads/main"
+	}
+	data.Branch = strings.TrimPrefix(data.Ref, "refs/heads/")
+	if data.Number == 0 {
+		data.Number = 1
+	}
+	if data.Title == "" {
+		data.Title = fmt.Sprintf("Emulated %s event", emu.Event)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&rendered, emu.Event, data); err != nil {
+		return nil, nil, fmt.Errorf("template: %w", err)
+	}
+	// GitHub sends compact JSON; this also catches templates that render invalid JSON
+	var body bytes.Buffer
+	if err := json.Compact(&body, rendered.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("template for %q did not render valid JSON: %w", emu.Event, err)
+	}
+
+	headers := map[string]string{
+		"User-Agent":                             "GitHub-Hookshot/" + randomHex(4)[:7],
+		"X-GitHub-Event":                         emu.Event,
+		"X-GitHub-Delivery":                      data.DeliveryID,
+		"X-GitHub-Hook-ID":                       fmt.Sprint(data.HookID),
+		"X-GitHub-Hook-Installation-Target-Type": "repository",
+		"X-GitHub-Hook-Installation-Target-ID":   fmt.Sprint(data.RepoID),
+	}
+	return body.Bytes(), headers, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HandshakeConfig answers the URL-verification requests providers send before
+// they start delivering events to an endpoint
+type HandshakeConfig struct {
+	Providers   []string `json:"providers,omitempty"`   // slack, meta, msgraph, zoom, challenge (default all)
+	VerifyToken string   `json:"verifyToken,omitempty"` // meta: expected hub.verify_token (any when empty)
+	ZoomSecret  string   `json:"zoomSecret,omitempty"`  // zoom: app secret token used for encryptedToken
+}
+
+// HandshakeResult is stored on captures that were answered as a handshake
+type HandshakeResult struct {
+	Provider  string `json:"provider"`
+	Challenge string `json:"challenge"`
+	Status    int    `json:"status"`
+	Response  string `json:"response"`
+	Error     string `json:"error,omitempty"`
+}
+
+const flagHandshake = "handshake"
+
+// handshakeDetector recognises one provider's handshake and works out the answer
+type handshakeDetector func(cfg *HandshakeConfig, r *http.Request, body []byte) (result HandshakeResult, contentType string, ok bool)
+
+var handshakeDetectors = []struct {
+	provider string
+	detect   handshakeDetector
+}{
+	{"slack", detectSlackHandshake},
+	{"zoom", detectZoomHandshake},
+	{"meta", detectMetaHandshake},
+	{"msgraph", detectGraphHandshake},
+	{"challenge", detectChallengeHandshake},
+}
+
+func (cfg *HandshakeConfig) validate() error {
+	for _, provider := range cfg.Providers {
+		known := false
+		for _, detector := range handshakeDetectors {
+			known = known || detector.provider == provider
+		}
+		if !known {
+			return fmt.Errorf("unknown handshake provider %q", provider)
+		}
+	}
+	return nil
+}
+
+func (cfg *HandshakeConfig) enabled(provider string) bool {
+	if len(cfg.Providers) == 0 {
+		return true
+	}
+	for _, name := range cfg.Providers {
+		if name == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// answerHandshake replies to the request if it is a handshake of an enabled provider
+func answerHandshake(w http.ResponseWriter, r *http.Request, body []byte, cfg *HandshakeConfig) (HandshakeResult, bool) {
+	for _, detector := range handshakeDetectors {
+		if !cfg.enabled(detector.provider) {
+			continue
+		}
+		result, contentType, ok := detector.detect(cfg, r, body)
+		if !ok {
+			continue
+		}
+		result.Provider = detector.provider
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(result.Status)
+		w.Write([]byte(result.Response))
+		return result, true
+	}
+	return HandshakeResult{}, false
+}
+
+// Slack Events API: {"type": "url_verification", "challenge": "..."}, answered with the challenge
+func detectSlackHandshake(cfg *HandshakeConfig, r *http.Request, body []byte) (HandshakeResult, string, bool) {
+	var message struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+	}
+	if r.Method != "POST" || json.Unmarshal(body, &message) != nil || message.Type != "url_verification" || message.Challenge == "" {
+		return HandshakeResult{}, "", false
+	}
+	return HandshakeResult{Challenge: message.Challenge, Status: http.StatusOK, Response: message.Challenge}, "text/plain", true
+}
+
+// Zoom: {"event": "endpoint.url_validation", "payload": {"plainToken": "..."}}, answered
+// with the token and its HMAC-SHA256 under the app's secret token
+func detectZoomHandshake(cfg *HandshakeConfig, r *http.Request, body []byte) (HandshakeResult, string, bool) {
+	var message struct {
+		Event   string `json:"event"`
+		Payload struct {
+			PlainToken string `json:"plainToken"`
+		} `json:"payload"`
+	}
+	if r.Method != "POST" || json.Unmarshal(body, &message) != nil || message.Event != "endpoint.url_validation" || message.Payload.PlainToken == "" {
+		return HandshakeResult{}, "", false
+	}
+
+	result := HandshakeResult{Challenge: message.Payload.PlainToken}
+	if cfg.ZoomSecret == "" {
+		result.Status = http.StatusInternalServerError
+		result.Error = "zoomSecret is not configured"
+		result.Response = result.Error
+		return result, "text/plain", true
+	}
+	response, _ := json.Marshal(map[string]string{
+		"plainToken":     message.Payload.PlainToken,
+		"encryptedToken": hex.EncodeToString(computeHMAC(sha256.New, cfg.ZoomSecret, []byte(message.Payload.PlainToken))),
+	})
+	result.Status = http.StatusOK
+	result.Response = string(response)
+	return result, "application/json", true
+}
+
+// Meta (Facebook, Instagram, WhatsApp): GET ?hub.mode=subscribe&hub.verify_token=...&hub.challenge=...
+func detectMetaHandshake(cfg *HandshakeConfig, r *http.Request, body []byte) (HandshakeResult, string, bool) {
+	query := r.URL.Query()
+	if r.Method != "GET" || query.Get("hub.mode") != "subscribe" || query.Get("hub.challenge") == "" {
+		return HandshakeResult{}, "", false
+	}
+
+	result := HandshakeResult{Challenge: query.Get("hub.challenge")}
+	if cfg.VerifyToken != "" && !secureEqual(query.Get("hub.verify_token"), cfg.VerifyToken) {
+		result.Status = http.StatusForbidden
+		result.Error = "hub.verify_token does not match"
+		result.Response = "Forbidden"
+		return result, "text/plain", true
+	}
+	result.Status = http.StatusOK
+	result.Response = result.Challenge
+	return result, "text/plain", true
+}
+
+// Microsoft Graph subscriptions: ?validationToken=..., echoed back as plain text
+func detectGraphHandshake(cfg *HandshakeConfig, r *http.Request, body []byte) (HandshakeResult, string, bool) {
+	token := r.URL.Query().Get("validationToken")
+	if token == "" {
+		return HandshakeResult{}, "", false
+	}
+	return HandshakeResult{Challenge: token, Status: http.StatusOK, Response: token}, "text/plain", true
+}
+
+// Dropbox and other GET ?challenge=... verifications, echoed back as plain text
+func detectChallengeHandshake(cfg *HandshakeConfig, r *http.Request, body []byte) (HandshakeResult, string, bool) {
+	challenge := r.URL.Query().Get("challenge")
+	if r.Method != "GET" || challenge == "" {
+		return HandshakeResult{}, "", false
+	}
+	return HandshakeResult{Challenge: challenge, Status: http.StatusOK, Response: challenge}, "text/plain", true
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTConfig verifies an "Authorization: Bearer <jwt>" header against keys in a local file
+type JWTConfig struct {
+	JWKSFile       string   `json:"jwksFile,omitempty"` // JSON Web Key Set
+	PEMFile        string   `json:"pemFile,omitempty"`  // PEM public key or certificate
+	Issuer         string   `json:"issuer,omitempty"`
+	Audience       string   `json:"audience,omitempty"`
+	RequiredClaims []string `json:"requiredClaims,omitempty"`
+	LeewaySeconds  int      `json:"leewaySeconds,omitempty"` // clock skew allowed for exp/nbf/iat
+	Enforce        bool     `json:"enforce,omitempty"`       // reject invalid tokens with 401
+}
+
+// JWTResult is the verification outcome and decoded token stored on the captured request
+type JWTResult struct {
+	Valid     bool                   `json:"valid"`
+	Error     string                 `json:"error,omitempty"`
+	Algorithm string                 `json:"alg,omitempty"`
+	KeyID     string                 `json:"kid,omitempty"`
+	Header    map[string]interface{} `json:"header,omitempty"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtKey struct {
+	id  string
+	key crypto.PublicKey
+}
+
+func verifyJWT(cfg *JWTConfig, r *http.Request) JWTResult {
+	var result JWTResult
+
+	// The auth scheme is case-insensitive (RFC 7235)
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		result.Error = "missing Authorization: Bearer token"
+		return result
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		result.Error = "token is not a compact JWS"
+		return result
+	}
+
+	if err := decodeJWTSegment(parts[0], &result.Header); err != nil {
+		result.Error = fmt.Sprintf("invalid header: %v", err)
+		return result
+	}
+	if err := decodeJWTSegment(parts[1], &result.Claims); err != nil {
+		result.Error = fmt.Sprintf("invalid claims: %v", err)
+		return result
+	}
+	result.Algorithm, _ = result.Header["alg"].(string)
+	result.KeyID, _ = result.Header["kid"].(string)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		result.Error = "signature is not base64url"
+		return result
+	}
+
+	keys, err := loadJWTKeys(cfg)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if result.KeyID != "" && key.id != "" && key.id != result.KeyID {
+			continue
+		}
+		if verifyJWS(result.Algorithm, key.key, signed, signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		result.Error = fmt.Sprintf("no key verifies %s signature (kid %q)", result.Algorithm, result.KeyID)
+		return result
+	}
+
+	if err := checkJWTClaims(cfg, result.Claims); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Valid = true
+	return result
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func checkJWTClaims(cfg *JWTConfig, claims map[string]interface{}) error {
+	now := time.Now()
+	leeway := time.Duration(cfg.LeewaySeconds) * time.Second
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return fmt.Errorf("token expired at %s", time.Unix(int64(exp), 0).Format(time.RFC3339))
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not valid before %s", time.Unix(int64(nbf), 0).Format(time.RFC3339))
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(iat), 0)) {
+		return fmt.Errorf("token issued in the future")
+	}
+
+	if cfg.Issuer != "" && claims["iss"] != cfg.Issuer {
+		return fmt.Errorf("issuer %v does not match %q", claims["iss"], cfg.Issuer)
+	}
+
+	if cfg.Audience != "" {
+		matched := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			matched = aud == cfg.Audience
+		case []interface{}:
+			for _, a := range aud {
+				if a == cfg.Audience {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return fmt.Errorf("audience %v does not include %q", claims["aud"], cfg.Audience)
+		}
+	}
+
+	for _, name := range cfg.RequiredClaims {
+		if _, ok := claims[name]; !ok {
+			return fmt.Errorf("missing required claim %q", name)
+		}
+	}
+	return nil
+}
+
+func verifyJWS(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		if pub, ok := key.(ed25519.PublicKey); ok && ed25519.Verify(pub, signed, signature) {
+			return nil
+		}
+		return fmt.Errorf("invalid EdDSA signature")
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if strings.HasPrefix(alg, "ES") {
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				return fmt.Errorf("invalid ECDSA signature length")
+			}
+			rInt := new(big.Int).SetBytes(signature[:size])
+			sInt := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(pub, digest, rInt, sInt) {
+				return nil
+			}
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+	}
+	return fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+}
+
+// loadJWTKeys reads the configured key file on every request so edits apply without a restart
+func loadJWTKeys(cfg *JWTConfig) ([]jwtKey, error) {
+	var keys []jwtKey
+	var skipped []string // unusable JWKS keys
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWKS: %w", err)
+		}
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("parsing JWKS: %w", err)
+		}
+		// Published sets often mix in encryption and symmetric keys; only the
+		// signature keys this server can use count
+		for _, k := range set.Keys {
+			if k.Use == "enc" {
+				skipped = append(skipped, fmt.Sprintf("%q: encryption key", k.Kid))
+				continue
+			}
+			pub, err := k.publicKey()
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%q: %v", k.Kid, err))
+				continue
+			}
+			keys = append(keys, jwtKey{id: k.Kid, key: pub})
+		}
+	}
+
+	if cfg.PEMFile != "" {
+		data, err := os.ReadFile(cfg.PEMFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading PEM: %w", err)
+		}
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			pub, err := parsePEMPublicKey(block)
+			if err != nil {
+				return nil, fmt.Errorf("PEM block %s: %w", block.Type, err)
+			}
+			keys = append(keys, jwtKey{key: pub})
+		}
+	}
+
+	if len(keys) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("no usable verification keys (skipped JWKS keys %s)", strings.Join(skipped, ", "))
+		}
+		return nil, fmt.Errorf("no verification keys configured")
+	}
+	return keys, nil
+}
+
+func parsePEMPublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM type")
+	}
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n")
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x")
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid x")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
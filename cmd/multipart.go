@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+// MultipartConfig is an opt-in policy for the files of multipart requests.
+// Without it every part is captured whatever its type.
+type MultipartConfig struct {
+	AllowedTypes []string `json:"allowedTypes,omitempty"` // e.g. "application/pdf" or "image/*"
+	SingleType   bool     `json:"singleType,omitempty"`   // all files must share one Content-Type
+	Enforce      bool     `json:"enforce,omitempty"`      // reject violating requests with 415 instead of only flagging them
+}
+
+const flagMultipartPolicy = "multipart-policy"
+
+func (cfg *MultipartConfig) validate() error {
+	for _, pattern := range cfg.AllowedTypes {
+		if !strings.Contains(pattern, "/") {
+			return fmt.Errorf("allowed type %q must look like type/subtype or type/*", pattern)
+		}
+	}
+	return nil
+}
+
+// check returns the policy rules the files break
+func (cfg *MultipartConfig) check(files []FileInfo) []string {
+	var violations []string
+	for _, file := range files {
+		if len(cfg.AllowedTypes) > 0 && !typeAllowed(file.ContentType, cfg.AllowedTypes) {
+			violations = append(violations, fmt.Sprintf("part %d (%s): type %q is not allowed", file.Index, file.Filename, file.ContentType))
+		}
+	}
+	if cfg.SingleType {
+		for _, file := range files {
+			if mediaTypeOf(file.ContentType) != mediaTypeOf(files[0].ContentType) {
+				violations = append(violations, fmt.Sprintf("part %d (%s): type %q differs from the first file's %q", file.Index, file.Filename, file.ContentType, files[0].ContentType))
+			}
+		}
+	}
+	return violations
+}
+
+func typeAllowed(contentType string, patterns []string) bool {
+	mediaType := mediaTypeOf(contentType)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*/*" || pattern == mediaType ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaTypeOf(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(contentType)
+}
+
+// readMultipartParts reads every part in the order it was sent. Form values are
+// returned by field name; file parts are put in file storage under the capture's
+// ID and part index, and described in order with their headers.
+func readMultipartParts(requestID, contentType string, body []byte) (map[string][]string, []FileInfo, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if params["boundary"] == "" {
+		return nil, nil, fmt.Errorf("no boundary in Content-Type")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	values := make(map[string][]string)
+	var files []FileInfo
+
+	for index := 0; ; index++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		content, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fieldName := part.FormName()
+		if part.FileName() == "" {
+			if fieldName == "" {
+				log.Printf("  Part %d: no field name, skipped", index)
+				continue
+			}
+			values[fieldName] = append(values[fieldName], string(content))
+			continue
+		}
+
+		key := storedFileKey(requestID, strconv.Itoa(index), part.FileName())
+		fileInfo := FileInfo{
+			Field:       fieldName,
+			Index:       index,
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        int64(len(content)),
+			Headers:     part.Header,
+			DownloadURL: downloadURL(key),
+			StoredAs:    key,
+		}
+		log.Printf("  Part %d: field %s, file %s, %s, %d bytes", index, fieldName, fileInfo.Filename, fileInfo.ContentType, fileInfo.Size)
+
+		// Store file content for download
+		fileStorageMux.Lock()
+		fileStorage[key] = content
+		fileStorageMux.Unlock()
+
+		files = append(files, fileInfo)
+	}
+	return values, files, nil
+}
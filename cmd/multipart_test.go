@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+)
+
+func storedFile(key string) ([]byte, bool) {
+	fileStorageMux.RLock()
+	defer fileStorageMux.RUnlock()
+	content, ok := fileStorage[key]
+	return content, ok
+}
+
+func TestReadMultipartPartsKeepsSameNamedFiles(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("note", "two reports")
+	for _, content := range []string{"first", "second"} {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="file"; filename="report.pdf"`)
+		header.Set("Content-Type", "application/pdf")
+		part, _ := writer.CreatePart(header)
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	values, files, err := readMultipartParts("req-same-name", writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deleteCaptureFiles([]WebhookRequest{{ID: "req-same-name"}}) })
+
+	if len(values["note"]) != 1 {
+		t.Errorf("form values = %v", values)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	for i, want := range []string{"first", "second"} {
+		if files[i].Index != i+1 {
+			t.Errorf("file %d index = %d, want %d", i, files[i].Index, i+1)
+		}
+		if content, _ := storedFile(files[i].StoredAs); string(content) != want {
+			t.Errorf("file %d stored as %q holds %q, want %q", i, files[i].StoredAs, content, want)
+		}
+	}
+}
+
+func TestCaptureFilesLeaveWithHistory(t *testing.T) {
+	withHistory(t, nil)
+
+	store := func(id string) string {
+		key := storedFileKey(id, "0", "data.csv")
+		fileStorageMux.Lock()
+		fileStorage[key] = []byte(id)
+		fileStorageMux.Unlock()
+		return key
+	}
+
+	oldest := store("req-hist-1")
+	kept := store("req-hist-10")
+	addRequest(WebhookRequest{ID: "req-hist-1", Provider: "test"})
+	for i := 2; i <= 100; i++ {
+		addRequest(WebhookRequest{ID: fmt.Sprintf("req-hist-%d", i), Provider: "test"})
+	}
+	if _, ok := storedFile(oldest); !ok {
+		t.Fatal("file removed while its capture is still in history")
+	}
+
+	addRequest(WebhookRequest{ID: "req-hist-101", Provider: "test"})
+	if _, ok := storedFile(oldest); ok {
+		t.Error("file kept after its capture left history")
+	}
+	if _, ok := storedFile(kept); !ok {
+		t.Error("file of a capture with a longer ID sharing the prefix was removed")
+	}
+
+	held := store("req-held")
+	r := httptest.NewRequest("DELETE", "/api/clear", nil)
+	handleClearRequests(httptest.NewRecorder(), r)
+	if _, ok := storedFile(kept); ok {
+		t.Error("file kept after clearing history")
+	}
+	if _, ok := storedFile(held); !ok {
+		t.Error("file of a capture outside history removed by clear")
+	}
+	deleteCaptureFiles([]WebhookRequest{{ID: "req-held"}})
+}
+
+func TestMultipartPolicy(t *testing.T) {
+	pdf := FileInfo{Index: 0, Filename: "a.pdf", ContentType: "application/pdf"}
+	png := FileInfo{Index: 1, Filename: "b.png", ContentType: "image/png"}
+	jpeg := FileInfo{Index: 2, Filename: "c.jpg", ContentType: "image/jpeg; charset=binary"}
+
+	tests := []struct {
+		name   string
+		cfg    MultipartConfig
+		files  []FileInfo
+		broken int
+	}{
+		{"no policy", MultipartConfig{}, []FileInfo{pdf, png}, 0},
+		{"allowed exact and wildcard", MultipartConfig{AllowedTypes: []string{"application/pdf", "image/*"}}, []FileInfo{pdf, png, jpeg}, 0},
+		{"type not allowed", MultipartConfig{AllowedTypes: []string{"image/*"}}, []FileInfo{pdf, png}, 1},
+		{"single type", MultipartConfig{SingleType: true}, []FileInfo{png, jpeg}, 1},
+		{"single type satisfied", MultipartConfig{SingleType: true}, []FileInfo{png, png}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.check(tt.files); len(got) != tt.broken {
+				t.Errorf("violations = %v, want %d", got, tt.broken)
+			}
+		})
+	}
+
+	if err := (&MultipartConfig{AllowedTypes: []string{"pdf"}}).validate(); err == nil {
+		t.Error("allowed type without a slash accepted")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// relayClient streams captures for one endpoint from a remote server over /ws and
+// re-issues them against a local URL, similar to `stripe listen --forward-to`
+type relayClient struct {
+	server   *url.URL
+	endpoint string
+	target   string
+	token    string
+	lastSeq  uint64
+	seen     map[uint64]bool
+	http     *http.Client
+}
+
+func runRelay(args []string) {
+	flags := flag.NewFlagSet("relay", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "remote webhook server URL")
+	endpoint := flags.String("bin", defaultEndpointName, "endpoint to subscribe to (the {name} in /webhook/{name})")
+	target := flags.String("to", "", "local URL to deliver each captured request to")
+	token := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin token for the remote server")
+	since := flags.Uint64("since", 0, "also deliver captures after this sequence number (default: only new ones)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s relay -server URL -bin NAME -to URL\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *target == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	serverURL, err := url.Parse(strings.TrimSuffix(*server, "/"))
+	if err != nil || serverURL.Host == "" {
+		log.Fatalf("Invalid server URL %q", *server)
+	}
+
+	relay := &relayClient{
+		server:   serverURL,
+		endpoint: *endpoint,
+		target:   *target,
+		token:    *token,
+		lastSeq:  *since,
+		seen:     make(map[uint64]bool),
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+	resume := *since > 0
+
+	log.Printf("Relaying %s/webhook/%s -> %s", serverURL, relay.endpoint, relay.target)
+
+	backoff := time.Second
+	for {
+		connected, err := relay.listen(resume)
+		if err != nil {
+			log.Printf("Relay connection lost: %v", err)
+		}
+		if connected {
+			resume = true
+			// A failed delivery keeps backing off; it is retried on reconnect
+			if !errors.Is(err, errRelayDelivery) {
+				backoff = time.Second
+			}
+		}
+
+		log.Printf("Reconnecting in %s (resuming after seq %d)", backoff, relay.lastSeq)
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// errRelayDelivery ends a session when a capture could not be delivered, so the
+// next session resumes from the last capture that was
+var errRelayDelivery = errors.New("delivery failed")
+
+// errCaptureGone means the server no longer holds the capture's body
+var errCaptureGone = errors.New("capture is no longer available")
+
+// listen runs one WebSocket session. It reports whether the connection was
+// established so the caller can reset its backoff.
+func (c *relayClient) listen(resume bool) (bool, error) {
+	wsURL := *c.server
+	switch wsURL.Scheme {
+	case "https", "wss":
+		wsURL.Scheme = "wss"
+	default:
+		wsURL.Scheme = "ws"
+	}
+	wsURL.Path += "/ws"
+
+	query := url.Values{}
+	query.Set("endpoint", c.endpoint)
+	if resume {
+		query.Set("since", strconv.FormatUint(c.lastSeq, 10))
+	} else {
+		query.Set("since", "latest")
+	}
+	wsURL.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), c.authHeader())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	log.Printf("Connected to %s", c.server)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+
+		var message struct {
+			Event string `json:"event"`
+			Seq   uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(data, &message); err != nil {
+			continue
+		}
+
+		if message.Event != "" {
+			if message.Event == "connected" {
+				switch {
+				case !resume && c.lastSeq == 0:
+					// On a fresh start only captures after this point are relayed
+					c.lastSeq = message.Seq
+				case message.Seq < c.lastSeq:
+					// The server restarted and numbers captures from 1 again; start
+					// over so the captures it has taken since are not skipped
+					c.lastSeq = 0
+					c.seen = make(map[uint64]bool)
+					return true, fmt.Errorf("server restarted (latest seq %d), resuming from its start", message.Seq)
+				}
+			}
+			continue
+		}
+
+		if c.seen[message.Seq] {
+			continue
+		}
+		var request WebhookRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			log.Printf("Skipping malformed capture: %v", err)
+			continue
+		}
+
+		if err := c.deliver(request); err != nil {
+			return true, fmt.Errorf("%w for seq %d: %v", errRelayDelivery, request.Seq, err)
+		}
+		c.seen[request.Seq] = true
+		if request.Seq > c.lastSeq {
+			c.lastSeq = request.Seq
+		}
+		c.pruneSeen()
+	}
+}
+
+// deliver fetches the captured body from the server and sends it to the local
+// target. Any answer from the target counts as delivered; an error means the
+// capture should be tried again.
+func (c *relayClient) deliver(request WebhookRequest) error {
+	body, contentType, err := c.fetchRawBody(request.ID)
+	if errors.Is(err, errCaptureGone) {
+		log.Printf("[%d] %s: skipped, %v", request.Seq, request.ID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch body: %w", err)
+	}
+
+	targetURL, err := url.Parse(c.target)
+	if err != nil {
+		return fmt.Errorf("invalid target URL: %w", err)
+	}
+	query := targetURL.Query()
+	for key, values := range request.Query {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	targetURL.RawQuery = query.Encode()
+
+	outbound, err := http.NewRequest(request.Method, targetURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range request.Headers {
+		outbound.Header[name] = append([]string(nil), values...)
+	}
+	for _, name := range hopHeaders {
+		outbound.Header.Del(name)
+	}
+	if request.Encoding.decoded() {
+		outbound.Header.Del("Content-Encoding")
+	}
+	if contentType != "" {
+		outbound.Header.Set("Content-Type", contentType)
+	}
+
+	log.Printf("--> %s %s [%s]", request.Method, request.URL, request.ID)
+	start := time.Now()
+	resp, err := c.http.Do(outbound)
+	if err != nil {
+		log.Printf("<-- error: %v", err)
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	log.Printf("<-- %d %s (%dms)", resp.StatusCode, http.StatusText(resp.StatusCode), time.Since(start).Milliseconds())
+	return nil
+}
+
+func (c *relayClient) fetchRawBody(id string) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/requests/%s/raw", c.server, url.PathEscape(id)), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header = c.authHeader()
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, "", fmt.Errorf("%w (%s)", errCaptureGone, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("server answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.Header.Get("Content-Type"), err
+}
+
+func (c *relayClient) authHeader() http.Header {
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	return header
+}
+
+// pruneSeen keeps the duplicate filter bounded to the server's history size
+func (c *relayClient) pruneSeen() {
+	if len(c.seen) <= 200 {
+		return
+	}
+	for seq := range c.seen {
+		if seq+200 < c.lastSeq {
+			delete(c.seen, seq)
+		}
+	}
+}
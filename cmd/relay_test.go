@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialCaptures(t *testing.T, query string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCapturesReachClientsInSeqOrder(t *testing.T) {
+	withHistory(t, []WebhookRequest{{ID: "req-before", Seq: 1, Endpoint: "relay"}})
+
+	conn := dialCaptures(t, "endpoint=relay&since=0")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	const captures = 200
+	var wg sync.WaitGroup
+	for i := 0; i < captures; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			addRequest(WebhookRequest{ID: fmt.Sprintf("req-order-%d", i), Endpoint: "relay", Provider: "test"})
+		}(i)
+	}
+	wg.Wait()
+
+	var lastSeq uint64
+	received := 0
+	for received < captures {
+		var message struct {
+			Event string `json:"event"`
+			ID    string `json:"id"`
+			Seq   uint64 `json:"seq"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("after %d captures: %v", received, err)
+		}
+		if message.Event != "" {
+			continue
+		}
+		if message.Seq <= lastSeq {
+			t.Fatalf("capture %s has seq %d after seq %d", message.ID, message.Seq, lastSeq)
+		}
+		lastSeq = message.Seq
+		if message.ID != "req-before" {
+			received++
+		}
+	}
+}
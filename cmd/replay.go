@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ReplayAttempt records one re-send of a captured request and the target's answer
+type ReplayAttempt struct {
+	ID              string              `json:"id"`
+	SourceID        string              `json:"sourceId"`
+	Target          string              `json:"target"`
+	Timestamp       time.Time           `json:"timestamp"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	Status          int                 `json:"status,omitempty"`
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string              `json:"responseBody,omitempty"`
+	LatencyMs       int64               `json:"latencyMs"`
+	Error           string              `json:"error,omitempty"`
+}
+
+type replayRequest struct {
+	Target string       `json:"target"`
+	Patch  *ReplayPatch `json:"patch,omitempty"`
+}
+
+// ReplayPatch edits a captured request before it is resent. The edited copy is
+// stored as a new capture whose DerivedFrom points back at the source.
+type ReplayPatch struct {
+	SetHeaders    map[string]string      `json:"setHeaders,omitempty"`
+	RemoveHeaders []string               `json:"removeHeaders,omitempty"`
+	Body          json.RawMessage        `json:"body,omitempty"`   // RFC 7386 JSON merge patch for JSON bodies
+	Fields        map[string]interface{} `json:"fields,omitempty"` // form field replacements; null removes a field
+	Files         []FilePatch            `json:"files,omitempty"`
+}
+
+// FilePatch replaces, adds or removes the attachment of one multipart field
+type FilePatch struct {
+	Field       string `json:"field"`
+	Remove      bool   `json:"remove,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     string `json:"content,omitempty"`    // base64 file bytes
+	StoredFile  string `json:"storedFile,omitempty"` // reuse a file already in storage
+}
+
+// Upper bound on how much of a target's response body is kept
+const maxRecordedResponseBody = 1 << 20
+
+var replayClient = &http.Client{Timeout: 30 * time.Second}
+
+// Headers that describe the original connection rather than the request itself
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authorization", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Content-Length", "Host", "Accept-Encoding",
+}
+
+// handleRequestByID serves /api/requests/{id}, /api/requests/{id}/raw and /api/requests/{id}/replay
+func handleRequestByID(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/requests/"), "/")
+	original, ok := findRequest(id)
+	if !ok {
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(original)
+
+	case action == "raw" && r.Method == "GET":
+		// The body as it should be re-sent, used by the relay command
+		body, contentType := original.RawBody, original.ContentType
+		if isMultipartRequest(original) {
+			var err error
+			body, contentType, err = buildMultipartBody(original)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusGone)
+				return
+			}
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write(body)
+
+	case action == "replay" && r.Method == "POST":
+		var params replayRequest
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Target == "" {
+			http.Error(w, "Body must be JSON with a target URL", http.StatusBadRequest)
+			return
+		}
+
+		if params.Patch != nil {
+			derived, err := applyReplayPatch(original, params.Patch)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			addRequest(derived)
+			log.Printf("Stored edited copy of %s as %s", original.ID, derived.ID)
+			original = derived
+		}
+
+		outbound, err := buildReplayRequest(original, params.Target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		attempt := sendReplay(original.ID, params.Target, outbound)
+		recordReplay(original.ID, attempt)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attempt)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func findRequest(id string) (WebhookRequest, bool) {
+	requestsMux.RLock()
+	defer requestsMux.RUnlock()
+
+	for _, request := range requests {
+		if request.ID == id {
+			return request, true
+		}
+	}
+	return WebhookRequest{}, false
+}
+
+// recordReplay attaches an attempt to its source request if it is still in history
+func recordReplay(id string, attempt ReplayAttempt) {
+	updateRequest(id, func(request *WebhookRequest) {
+		request.Replays = append(request.Replays, attempt)
+	})
+}
+
+// buildReplayRequest recreates the captured request against target, keeping the
+// original method, headers, query parameters and body
+func buildReplayRequest(original WebhookRequest, target string) (*http.Request, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid target URL %q", target)
+	}
+
+	query := targetURL.Query()
+	for key, values := range original.Query {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	targetURL.RawQuery = query.Encode()
+
+	header := http.Header{}
+	for name, values := range original.Headers {
+		header[name] = append([]string(nil), values...)
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+	if original.Encoding.decoded() {
+		header.Del("Content-Encoding")
+	}
+
+	body := original.RawBody
+	if isMultipartRequest(original) {
+		var contentType string
+		body, contentType, err = buildMultipartBody(original)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Content-Type", contentType)
+	}
+
+	outbound, err := http.NewRequest(original.Method, targetURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	outbound.Header = header
+	return outbound, nil
+}
+
+func isMultipartRequest(request WebhookRequest) bool {
+	return len(request.Files) > 0 || strings.HasPrefix(request.ContentType, "multipart/form-data")
+}
+
+// buildMultipartBody re-encodes captured form fields and files with a fresh boundary,
+// reading file bytes back from file storage
+func buildMultipartBody(original WebhookRequest) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fileFields := make(map[string]bool)
+	for _, file := range original.Files {
+		fileFields[file.Field] = true
+	}
+
+	if fields, ok := original.Body.(map[string]interface{}); ok {
+		for name, value := range fields {
+			if fileFields[name] {
+				continue
+			}
+			for _, v := range formValues(value) {
+				if err := writer.WriteField(name, v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+	}
+
+	for _, file := range original.Files {
+		fileStorageMux.RLock()
+		content, exists := fileStorage[file.storageKey()]
+		fileStorageMux.RUnlock()
+		if !exists {
+			return nil, "", fmt.Errorf("stored content for %s is no longer available", file.Filename)
+		}
+
+		partHeader := textproto.MIMEHeader{}
+		for name, values := range file.Headers {
+			partHeader[name] = append([]string(nil), values...)
+		}
+		partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, file.Field, file.Filename))
+		partHeader.Set("Content-Type", file.ContentType)
+		part, err := writer.CreatePart(partHeader)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(content)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+func formValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	default:
+		data, _ := json.Marshal(v)
+		return []string{string(data)}
+	}
+}
+
+func sendReplay(sourceID, target string, outbound *http.Request) ReplayAttempt {
+	attempt := ReplayAttempt{
+		ID:        fmt.Sprintf("replay-%d", time.Now().UnixNano()),
+		SourceID:  sourceID,
+		Target:    target,
+		Timestamp: time.Now(),
+		Method:    outbound.Method,
+		URL:       outbound.URL.String(),
+	}
+
+	log.Printf("Replaying %s to %s %s", sourceID, outbound.Method, attempt.URL)
+
+	start := time.Now()
+	resp, err := replayClient.Do(outbound)
+	if err != nil {
+		attempt.LatencyMs = time.Since(start).Milliseconds()
+		attempt.Error = err.Error()
+		log.Printf("Replay %s failed: %v", attempt.ID, err)
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponseBody))
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = fmt.Sprintf("reading response: %v", err)
+	}
+
+	attempt.Status = resp.StatusCode
+	attempt.ResponseHeaders = resp.Header
+	attempt.ResponseBody = string(body)
+
+	log.Printf("Replay %s: %d in %dms", attempt.ID, attempt.Status, attempt.LatencyMs)
+	return attempt
+}
+
+// applyReplayPatch returns an edited copy of original as a new capture. Only the
+// request itself is copied: checks, classification and forwarding results of the
+// original delivery do not hold for the edited one.
+func applyReplayPatch(original WebhookRequest, patch *ReplayPatch) (WebhookRequest, error) {
+	derived := WebhookRequest{
+		ID:          fmt.Sprintf("req-%d", time.Now().UnixNano()),
+		Timestamp:   time.Now(),
+		Method:      original.Method,
+		URL:         original.URL,
+		Query:       original.Query,
+		Endpoint:    original.Endpoint,
+		Body:        original.Body,
+		Files:       original.Files,
+		RawBody:     original.RawBody,
+		Encoding:    original.Encoding,
+		DerivedFrom: original.ID,
+	}
+
+	header := http.Header{}
+	for name, values := range original.Headers {
+		header[name] = append([]string(nil), values...)
+	}
+	for _, name := range patch.RemoveHeaders {
+		header.Del(name)
+	}
+	for name, value := range patch.SetHeaders {
+		header.Set(name, value)
+	}
+	derived.Headers = header
+	derived.ContentType = header.Get("Content-Type")
+
+	isMultipart := isMultipartRequest(original)
+	isForm := !isMultipart && isFormURLEncoded(original.ContentType)
+
+	if len(patch.Body) > 0 {
+		if isMultipart {
+			return derived, fmt.Errorf("body merge patch needs a JSON body; use fields for form data")
+		}
+		var target interface{}
+		if err := json.Unmarshal(original.RawBody, &target); err != nil {
+			return derived, fmt.Errorf("original body is not JSON")
+		}
+		var mergePatch interface{}
+		if err := json.Unmarshal(patch.Body, &mergePatch); err != nil {
+			return derived, fmt.Errorf("invalid body merge patch: %v", err)
+		}
+		derived.Body = applyMergePatch(target, mergePatch)
+		data, err := json.Marshal(derived.Body)
+		if err != nil {
+			return derived, err
+		}
+		derived.RawBody = data
+	}
+
+	if isForm && len(patch.Fields) > 0 {
+		if len(patch.Files) > 0 {
+			return derived, fmt.Errorf("files can only be patched on multipart requests")
+		}
+		values, err := url.ParseQuery(string(original.RawBody))
+		if err != nil {
+			return derived, fmt.Errorf("original form body is invalid: %v", err)
+		}
+		for name, value := range patch.Fields {
+			if value == nil {
+				values.Del(name)
+			} else {
+				values[name] = formValues(value)
+			}
+		}
+		derived.RawBody = []byte(values.Encode())
+		formData, err := parseFormBody(derived.RawBody)
+		if err != nil {
+			return derived, err
+		}
+		derived.Body = formData
+		return derived, nil
+	}
+
+	if len(patch.Fields) > 0 || len(patch.Files) > 0 {
+		if !isMultipart {
+			return derived, fmt.Errorf("fields can only be patched on form requests, and files on multipart requests")
+		}
+
+		fields := make(map[string]interface{})
+		if existing, ok := original.Body.(map[string]interface{}); ok {
+			for name, value := range existing {
+				fields[name] = value
+			}
+		}
+		for name, value := range patch.Fields {
+			if value == nil {
+				delete(fields, name)
+			} else {
+				fields[name] = value
+			}
+		}
+
+		files := append([]FileInfo(nil), original.Files...)
+		for _, filePatch := range patch.Files {
+			var err error
+			files, err = applyFilePatch(derived.ID, files, filePatch)
+			if err != nil {
+				return derived, err
+			}
+			if filePatch.Remove {
+				delete(fields, filePatch.Field)
+			} else {
+				fields[filePatch.Field] = fmt.Sprintf("FILE_UPLOADED_%s", filePatch.Field)
+			}
+		}
+
+		derived.Body = fields
+		derived.Files = files
+	}
+
+	return derived, nil
+}
+
+// applyFilePatch stores new file bytes under the derived capture's ID so the
+// source capture's downloads and replays keep their own content
+func applyFilePatch(requestID string, files []FileInfo, patch FilePatch) ([]FileInfo, error) {
+	if patch.Field == "" {
+		return nil, fmt.Errorf("file patch needs a field")
+	}
+
+	kept := files[:0:0]
+	for _, file := range files {
+		if file.Field != patch.Field {
+			kept = append(kept, file)
+		}
+	}
+	if patch.Remove {
+		return kept, nil
+	}
+
+	var content []byte
+	switch {
+	case patch.Content != "":
+		decoded, err := base64.StdEncoding.DecodeString(patch.Content)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: content is not base64", patch.Field)
+		}
+		content = decoded
+	case patch.StoredFile != "":
+		fileStorageMux.RLock()
+		stored, exists := fileStorage[patch.StoredFile]
+		fileStorageMux.RUnlock()
+		if !exists {
+			return nil, fmt.Errorf("stored file %s not found", patch.StoredFile)
+		}
+		content = stored
+	default:
+		return nil, fmt.Errorf("file %s: content or storedFile is required", patch.Field)
+	}
+
+	filename := patch.Filename
+	if filename == "" {
+		filename = patch.StoredFile
+	}
+	if filename == "" {
+		return nil, fmt.Errorf("file %s: filename is required", patch.Field)
+	}
+	filename = path.Base(filename)
+	contentType := patch.ContentType
+	if contentType == "" {
+		contentType = getContentType(filename)
+	}
+
+	key := storedFileKey(requestID, patch.Field, filename)
+	fileStorageMux.Lock()
+	fileStorage[key] = content
+	fileStorageMux.Unlock()
+
+	return append(kept, FileInfo{
+		Field:       patch.Field,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		DownloadURL: downloadURL(key),
+		StoredAs:    key,
+	}), nil
+}
+
+// applyMergePatch implements RFC 7386 JSON Merge Patch
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = applyMergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Cases from RFC 7386, Appendix A
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target+" + "+tt.patch, func(t *testing.T) {
+			var target, patch interface{}
+			if err := json.Unmarshal([]byte(tt.target), &target); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(applyMergePatch(target, patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyReplayPatchStartsAFreshCapture(t *testing.T) {
+	original := WebhookRequest{
+		ID:          "req-original",
+		Method:      "POST",
+		URL:         "/webhook/github",
+		Endpoint:    "github",
+		ContentType: "application/json",
+		Headers:     map[string][]string{"Content-Type": {"application/json"}, "X-Github-Event": {"push"}},
+		Body:        map[string]interface{}{"ref": "main"},
+		RawBody:     []byte(`{"ref":"main"}`),
+		Provider:    "github",
+		EventType:   "push",
+		Flags:       []string{flagUnauthorized},
+		Auth:        &AuthResult{Type: "header", Valid: false},
+		Signature:   &SignatureResult{Provider: "github", Valid: true},
+		JWT:         &JWTResult{Valid: true},
+		Upstream:    &UpstreamResponse{Status: 200},
+		Replays:     []ReplayAttempt{{ID: "replay-1"}},
+	}
+
+	derived, err := applyReplayPatch(original, &ReplayPatch{
+		SetHeaders: map[string]string{"X-Github-Event": "ping"},
+		Body:       []byte(`{"ref":"feature"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if derived.ID == original.ID || derived.DerivedFrom != original.ID {
+		t.Errorf("id = %q, derivedFrom = %q", derived.ID, derived.DerivedFrom)
+	}
+	if string(derived.RawBody) != `{"ref":"feature"}` || derived.Endpoint != "github" || derived.Method != "POST" {
+		t.Errorf("request not carried over: %s %s %s", derived.Method, derived.Endpoint, derived.RawBody)
+	}
+	if derived.Signature != nil || derived.JWT != nil || derived.Auth != nil || derived.Upstream != nil {
+		t.Error("results of the original delivery were copied")
+	}
+	if derived.Provider != "" || derived.EventType != "" || len(derived.Flags) != 0 || len(derived.Replays) != 0 {
+		t.Errorf("provider %q, event %q, flags %v, replays %d copied", derived.Provider, derived.EventType, derived.Flags, len(derived.Replays))
+	}
+}
+
+func TestApplyReplayPatchFormFields(t *testing.T) {
+	original := WebhookRequest{
+		ID:          "req-form",
+		Method:      "POST",
+		ContentType: "application/x-www-form-urlencoded",
+		Headers:     map[string][]string{"Content-Type": {"application/x-www-form-urlencoded"}},
+		RawBody:     []byte(`Body=hello&From=%2B15551234567&To=%2B15005550006`),
+	}
+
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		files   []FilePatch
+		want    string
+		wantErr bool
+	}{
+		{"replace", map[string]interface{}{"Body": "bye"}, nil, "Body=bye&From=%2B15551234567&To=%2B15005550006", false},
+		{"remove", map[string]interface{}{"To": nil}, nil, "Body=hello&From=%2B15551234567", false},
+		{"repeat", map[string]interface{}{"Tag": []interface{}{"a", "b"}}, nil, "Body=hello&From=%2B15551234567&Tag=a&Tag=b&To=%2B15005550006", false},
+		{"json value", map[string]interface{}{"payload": map[string]interface{}{"type": "block_actions"}}, nil, "Body=hello&From=%2B15551234567&To=%2B15005550006&payload=%7B%22type%22%3A%22block_actions%22%7D", false},
+		{"files", map[string]interface{}{"Body": "bye"}, []FilePatch{{Field: "media", Remove: true}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			derived, err := applyReplayPatch(original, &ReplayPatch{Fields: tt.fields, Files: tt.files})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(derived.RawBody) != tt.want {
+				t.Errorf("body = %s, want %s", derived.RawBody, tt.want)
+			}
+			if _, ok := derived.Body.(map[string]interface{}); !ok {
+				t.Errorf("structured body = %#v", derived.Body)
+			}
+		})
+	}
+}
+
+func TestThoughtSpotCaptureReplaysBody(t *testing.T) {
+	withHistory(t, nil)
+	payload := `{"notificationType":"SCHEDULED_REPORT"}`
+
+	r := httptest.NewRequest("POST", "/webhook/thoughtspot", strings.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	handleThoughtSpotWebhook(httptest.NewRecorder(), r)
+
+	requestsMux.RLock()
+	captured := requests[0]
+	requestsMux.RUnlock()
+
+	outbound, err := buildReplayRequest(captured, "http://localhost:3000/hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(outbound.Body)
+	if string(body) != payload {
+		t.Errorf("replayed body = %q, want %q", body, payload)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayJob re-delivers every capture matching a filter to one target in the background
+type ReplayJob struct {
+	ID              string          `json:"id"`
+	Status          string          `json:"status"` // running, completed or cancelled
+	Target          string          `json:"target"`
+	Filter          RequestFilter   `json:"filter"`
+	Concurrency     int             `json:"concurrency"`
+	RatePerSecond   float64         `json:"ratePerSecond,omitempty"`
+	PreserveSpacing bool            `json:"preserveSpacing,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	FinishedAt      *time.Time      `json:"finishedAt,omitempty"`
+	Total           int             `json:"total"`
+	Completed       int             `json:"completed"`
+	Succeeded       int             `json:"succeeded"`
+	Failed          int             `json:"failed"`
+	Items           []ReplayJobItem `json:"-"`
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// ReplayJobItem is the per-request result of a replay job
+type ReplayJobItem struct {
+	RequestID string         `json:"requestId"`
+	Status    string         `json:"status"` // pending, succeeded, failed or cancelled
+	Attempt   *ReplayAttempt `json:"attempt,omitempty"`
+}
+
+type replayJobRequest struct {
+	Target          string        `json:"target"`
+	Filter          RequestFilter `json:"filter"`
+	Concurrency     int           `json:"concurrency"`
+	RatePerSecond   float64       `json:"ratePerSecond"`
+	PreserveSpacing bool          `json:"preserveSpacing"`
+}
+
+var (
+	replayJobs    = make(map[string]*ReplayJob)
+	replayJobsMux sync.RWMutex
+)
+
+// handleReplayJobs serves /api/replay-jobs and /api/replay-jobs/{id}[/cancel|/results]
+func handleReplayJobs(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/replay-jobs"), "/")
+	if path == "" {
+		switch r.Method {
+		case "GET":
+			replayJobsMux.RLock()
+			list := make([]ReplayJob, 0, len(replayJobs))
+			for _, job := range replayJobs {
+				list = append(list, job.snapshot())
+			}
+			replayJobsMux.RUnlock()
+			sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+			writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": list, "count": len(list)})
+		case "POST":
+			createReplayJob(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, action, _ := strings.Cut(path, "/")
+	replayJobsMux.RLock()
+	job, exists := replayJobs[id]
+	replayJobsMux.RUnlock()
+	if !exists {
+		http.Error(w, "Replay job not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, job.snapshot())
+	case action == "results" && r.Method == "GET":
+		job.mu.Lock()
+		items := append([]ReplayJobItem(nil), job.Items...)
+		job.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": items, "count": len(items)})
+	case (action == "cancel" && r.Method == "POST") || (action == "" && r.Method == "DELETE"):
+		job.cancel()
+		log.Printf("Replay job %s cancel requested", job.ID)
+		writeJSON(w, http.StatusOK, job.snapshot())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createReplayJob(w http.ResponseWriter, r *http.Request) {
+	var params replayJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Target == "" {
+		http.Error(w, "Body must be JSON with a target URL", http.StatusBadRequest)
+		return
+	}
+	if err := params.Filter.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.Concurrency <= 0 {
+		params.Concurrency = 1
+	}
+
+	// Replay in the order the requests originally arrived
+	matched := filterRequests(params.Filter)
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &ReplayJob{
+		ID:              fmt.Sprintf("job-%d", time.Now().UnixNano()),
+		Status:          "running",
+		Target:          params.Target,
+		Filter:          params.Filter,
+		Concurrency:     params.Concurrency,
+		RatePerSecond:   params.RatePerSecond,
+		PreserveSpacing: params.PreserveSpacing,
+		CreatedAt:       time.Now(),
+		Total:           len(matched),
+		Items:           make([]ReplayJobItem, len(matched)),
+		cancel:          cancel,
+	}
+	for i, request := range matched {
+		job.Items[i] = ReplayJobItem{RequestID: request.ID, Status: "pending"}
+	}
+
+	replayJobsMux.Lock()
+	replayJobs[job.ID] = job
+	replayJobsMux.Unlock()
+
+	log.Printf("Replay job %s: %d requests to %s (concurrency %d, rate %.2f/s, preserve spacing %v)",
+		job.ID, job.Total, job.Target, job.Concurrency, job.RatePerSecond, job.PreserveSpacing)
+
+	go job.run(ctx, matched)
+
+	writeJSON(w, http.StatusAccepted, job.snapshot())
+}
+
+func (job *ReplayJob) run(ctx context.Context, matched []WebhookRequest) {
+	var interval time.Duration
+	if job.RatePerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / job.RatePerSecond)
+	}
+
+	slots := make(chan struct{}, job.Concurrency)
+	var wg sync.WaitGroup
+	var lastSent time.Time
+
+dispatch:
+	for i, request := range matched {
+		// Wait for the original gap to the previous request and/or the rate limit
+		var wait time.Duration
+		if job.PreserveSpacing && i > 0 {
+			wait = request.Timestamp.Sub(matched[i-1].Timestamp)
+		}
+		if interval > 0 && !lastSent.IsZero() {
+			if gap := interval - time.Since(lastSent); gap > wait {
+				wait = gap
+			}
+		}
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-time.After(wait):
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case slots <- struct{}{}:
+		}
+		lastSent = time.Now()
+
+		wg.Add(1)
+		go func(index int, request WebhookRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			job.replayItem(ctx, index, request)
+		}(i, request)
+	}
+
+	wg.Wait()
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	now := time.Now()
+	job.FinishedAt = &now
+	if ctx.Err() != nil {
+		job.Status = "cancelled"
+		for i := range job.Items {
+			if job.Items[i].Status == "pending" {
+				job.Items[i].Status = "cancelled"
+			}
+		}
+	} else {
+		job.Status = "completed"
+	}
+	job.cancel()
+	log.Printf("Replay job %s %s: %d succeeded, %d failed of %d", job.ID, job.Status, job.Succeeded, job.Failed, job.Total)
+}
+
+func (job *ReplayJob) replayItem(ctx context.Context, index int, request WebhookRequest) {
+	var attempt ReplayAttempt
+	outbound, err := buildReplayRequest(request, job.Target)
+	if err != nil {
+		attempt = ReplayAttempt{
+			ID:        fmt.Sprintf("replay-%d", time.Now().UnixNano()),
+			SourceID:  request.ID,
+			Target:    job.Target,
+			Timestamp: time.Now(),
+			Method:    request.Method,
+			Error:     err.Error(),
+		}
+	} else {
+		attempt = sendReplay(request.ID, job.Target, outbound.WithContext(ctx))
+		recordReplay(request.ID, attempt)
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.Completed++
+	item := &job.Items[index]
+	item.Attempt = &attempt
+	if attempt.Error == "" && attempt.Status < 400 {
+		item.Status = "succeeded"
+		job.Succeeded++
+	} else {
+		item.Status = "failed"
+		job.Failed++
+	}
+}
+
+// snapshot copies the job's progress under its lock for encoding
+func (job *ReplayJob) snapshot() ReplayJob {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return ReplayJob{
+		ID:              job.ID,
+		Status:          job.Status,
+		Target:          job.Target,
+		Filter:          job.Filter,
+		Concurrency:     job.Concurrency,
+		RatePerSecond:   job.RatePerSecond,
+		PreserveSpacing: job.PreserveSpacing,
+		CreatedAt:       job.CreatedAt,
+		FinishedAt:      job.FinishedAt,
+		Total:           job.Total,
+		Completed:       job.Completed,
+		Succeeded:       job.Succeeded,
+		Failed:          job.Failed,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
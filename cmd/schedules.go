@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	_ "time/tzdata" // time zones for minimal images without /usr/share/zoneinfo
+)
+
+// ScheduleConfig emits a templated delivery every time its cron expression matches
+type ScheduleConfig struct {
+	Name        string                `json:"name"`
+	Cron        string                `json:"cron"`               // five fields or @hourly, @daily, @weekly, @monthly, @yearly
+	TimeZone    string                `json:"timeZone,omitempty"` // IANA name (default UTC)
+	Target      string                `json:"target"`
+	Method      string                `json:"method,omitempty"`
+	Headers     map[string]string     `json:"headers,omitempty"`
+	ContentType string                `json:"contentType,omitempty"` // default application/json
+	Template    string                `json:"template"`              // Go text/template rendered for each run
+	ThoughtSpot *ThoughtSpotEmulation `json:"thoughtspot,omitempty"` // send ThoughtSpot scheduled reports instead of the template
+	Signing     *SigningConfig        `json:"signing,omitempty"`
+	Retry       RetryPolicy           `json:"retry"`
+	Paused      bool                  `json:"paused,omitempty"`
+}
+
+// Schedule is a running schedule and what it last did
+type Schedule struct {
+	ScheduleConfig
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty"`
+	Runs           int        `json:"runs"`
+	LastDeliveryID string     `json:"lastDeliveryId,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+
+	cron     *cronSchedule
+	location *time.Location
+	template *template.Template
+	mu       sync.Mutex
+	wake     chan struct{}
+	stop     chan struct{}
+}
+
+// ScheduleRun is the data available to a schedule's template
+type ScheduleRun struct {
+	ID             string    // unique per run
+	Schedule       string    // schedule name
+	Run            int       // 1 for the first run
+	RunTime        time.Time // when the run was due, in the schedule's time zone
+	NextRunTime    time.Time // the following run (zero if none)
+	ScheduleString string    // cron expression and time zone
+	Manual         bool      // triggered through the API
+}
+
+var (
+	schedules    = make(map[string]*Schedule)
+	schedulesMux sync.Mutex
+)
+
+// templateFuncs are available in schedule and emulator templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"add":     func(a, b int) int { return a + b },
+}
+
+// setSchedule validates cfg and starts it, replacing any schedule with the same name
+func setSchedule(cfg ScheduleConfig) (*Schedule, error) {
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("schedule name is required")
+	}
+	cron, err := parseCron(cfg.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", cfg.Name, err)
+	}
+	location := time.UTC
+	if cfg.TimeZone != "" {
+		if location, err = time.LoadLocation(cfg.TimeZone); err != nil {
+			return nil, fmt.Errorf("schedule %q: unknown time zone %q", cfg.Name, cfg.TimeZone)
+		}
+	}
+	tmpl, err := template.New(cfg.Name).Funcs(templateFuncs).Parse(cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", cfg.Name, err)
+	}
+	if cfg.ThoughtSpot != nil {
+		if err := cfg.ThoughtSpot.validate(); err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", cfg.Name, err)
+		}
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+	request := cfg.deliveryRequest()
+	if err := request.validate(); err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", cfg.Name, err)
+	}
+
+	schedule := &Schedule{
+		ScheduleConfig: cfg,
+		cron:           cron,
+		location:       location,
+		template:       tmpl,
+		wake:           make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+	}
+	schedule.plan()
+
+	schedulesMux.Lock()
+	if previous, exists := schedules[cfg.Name]; exists {
+		close(previous.stop)
+	}
+	schedules[cfg.Name] = schedule
+	schedulesMux.Unlock()
+
+	go schedule.loop()
+	return schedule, nil
+}
+
+func (cfg *ScheduleConfig) deliveryRequest() DeliveryRequest {
+	return DeliveryRequest{
+		Target:      cfg.Target,
+		Method:      cfg.Method,
+		Headers:     cfg.Headers,
+		ContentType: cfg.ContentType,
+		Signing:     cfg.Signing,
+		Retry:       cfg.Retry,
+	}
+}
+
+// plan computes the next due time. Callers hold s.mu.
+func (s *Schedule) plan() {
+	s.NextRunAt = nil
+	if s.Paused {
+		return
+	}
+	if next := s.cron.next(time.Now().In(s.location)); !next.IsZero() {
+		s.NextRunAt = &next
+	}
+}
+
+// loop waits for each due time and fires the schedule until it is replaced or deleted
+func (s *Schedule) loop() {
+	for {
+		var due <-chan time.Time
+		var timer *time.Timer
+		var next time.Time
+		s.mu.Lock()
+		if s.NextRunAt != nil {
+			next = *s.NextRunAt
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.stop:
+		case <-s.wake:
+		case <-due:
+			s.fire(next, false)
+			s.mu.Lock()
+			s.plan()
+			s.mu.Unlock()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// fire renders the template and starts a delivery
+func (s *Schedule) fire(runTime time.Time, manual bool) (*Delivery, error) {
+	s.mu.Lock()
+	s.Runs++
+	now := time.Now()
+	s.LastRunAt = &now
+	run := ScheduleRun{
+		ID:             fmt.Sprintf("%s-%d", s.Name, now.UnixNano()),
+		Schedule:       s.Name,
+		Run:            s.Runs,
+		RunTime:        runTime.In(s.location),
+		NextRunTime:    s.cron.next(runTime.In(s.location)),
+		ScheduleString: s.Cron + " " + s.location.String(),
+		Manual:         manual,
+	}
+	s.mu.Unlock()
+
+	request := s.deliveryRequest()
+	var body []byte
+	var err error
+	if s.ThoughtSpot != nil {
+		body, request.ContentType, err = s.ThoughtSpot.build(now, &run)
+	} else {
+		var rendered bytes.Buffer
+		err = s.template.Execute(&rendered, run)
+		body = rendered.Bytes()
+	}
+	var delivery *Delivery
+	if err == nil {
+		delivery, err = startDelivery(request, body, "schedule:"+s.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.LastError = err.Error()
+		log.Printf("Schedule %q run %d failed: %v", s.Name, run.Run, err)
+		return nil, err
+	}
+	s.LastError = ""
+	s.LastDeliveryID = delivery.ID
+	log.Printf("Schedule %q run %d started delivery %s", s.Name, run.Run, delivery.ID)
+	return delivery, nil
+}
+
+func (s *Schedule) setPaused(paused bool) {
+	s.mu.Lock()
+	s.Paused = paused
+	s.plan()
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot copies the schedule under its lock for encoding
+func (s *Schedule) snapshot() Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Schedule{
+		ScheduleConfig: s.ScheduleConfig,
+		NextRunAt:      s.NextRunAt,
+		LastRunAt:      s.LastRunAt,
+		Runs:           s.Runs,
+		LastDeliveryID: s.LastDeliveryID,
+		LastError:      s.LastError,
+	}
+}
+
+// handleSchedules serves /api/schedules and /api/schedules/{name}[/pause|/resume|/trigger]
+func handleSchedules(w http.ResponseWriter, r *http.Request) {
+	// Add CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	// Handle preflight OPTIONS request
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schedules"), "/")
+	if path == "" {
+		switch r.Method {
+		case "GET":
+			schedulesMux.Lock()
+			names := make([]string, 0, len(schedules))
+			for name := range schedules {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			list := make([]Schedule, 0, len(names))
+			for _, name := range names {
+				list = append(list, schedules[name].snapshot())
+			}
+			schedulesMux.Unlock()
+			writeJSON(w, http.StatusOK, map[string]interface{}{"schedules": list, "count": len(list)})
+
+		case "POST":
+			var cfg ScheduleConfig
+			if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+				http.Error(w, "Invalid schedule JSON", http.StatusBadRequest)
+				return
+			}
+			schedule, err := setSchedule(cfg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Schedule %q updated via API", schedule.Name)
+			writeJSON(w, http.StatusOK, schedule.snapshot())
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	name, action, _ := strings.Cut(path, "/")
+	schedulesMux.Lock()
+	schedule, exists := schedules[name]
+	schedulesMux.Unlock()
+	if !exists {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, schedule.snapshot())
+
+	case action == "" && r.Method == "DELETE":
+		schedulesMux.Lock()
+		if schedules[name] == schedule {
+			delete(schedules, name)
+			close(schedule.stop)
+		}
+		schedulesMux.Unlock()
+		log.Printf("Schedule %q removed via API", name)
+		w.WriteHeader(http.StatusNoContent)
+
+	case (action == "pause" || action == "resume") && r.Method == "POST":
+		schedule.setPaused(action == "pause")
+		log.Printf("Schedule %q %sd", name, action)
+		writeJSON(w, http.StatusOK, schedule.snapshot())
+
+	case action == "trigger" && r.Method == "POST":
+		delivery, err := schedule.fire(time.Now(), true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, delivery.snapshot())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureConfig selects a provider verification scheme for an endpoint
+type SignatureConfig struct {
+	Provider         string   `json:"provider"` // github, stripe, slack, shopify, twilio or standard-webhooks
+	Secret           string   `json:"secret"`
+	Secrets          []string `json:"secrets,omitempty"`          // additional secrets accepted while rotating (standard-webhooks)
+	ToleranceSeconds int      `json:"toleranceSeconds,omitempty"` // timestamp tolerance for stripe, slack and standard-webhooks (default 300)
+	URL              string   `json:"url,omitempty"`              // public URL the provider signs (twilio); defaults to the request URL
+	Enforce          bool     `json:"enforce,omitempty"`          // reject invalid signatures with 401
+}
+
+// SignatureResult is the verification outcome stored on the captured request
+type SignatureResult struct {
+	Provider    string `json:"provider"`
+	Valid       bool   `json:"valid"`
+	Error       string `json:"error,omitempty"`
+	MessageID   string `json:"messageId,omitempty"`   // webhook-id for standard-webhooks
+	DuplicateOf string `json:"duplicateOf,omitempty"` // earlier capture with the same message ID
+}
+
+type signatureVerifier func(cfg *SignatureConfig, r *http.Request, body []byte) error
+
+var signatureVerifiers = map[string]signatureVerifier{
+	"github":  verifyGitHubSignature,
+	"stripe":  verifyStripeSignature,
+	"slack":   verifySlackSignature,
+	"shopify": verifyShopifySignature,
+	"twilio":  verifyTwilioSignature,
+
+	"standard-webhooks": verifyStandardWebhook,
+}
+
+const defaultSignatureTolerance = 5 * time.Minute
+
+func verifySignature(cfg *SignatureConfig, endpoint string, r *http.Request, body []byte) SignatureResult {
+	result := SignatureResult{Provider: cfg.Provider}
+
+	// Standard Webhooks senders retry with the same webhook-id, so flag redeliveries
+	if cfg.Provider == "standard-webhooks" {
+		result.MessageID = r.Header.Get("webhook-id")
+		result.DuplicateOf = findRequestByHeader(endpoint, "webhook-id", result.MessageID)
+	}
+
+	verifier, ok := signatureVerifiers[cfg.Provider]
+	if !ok {
+		result.Error = fmt.Sprintf("unknown signature provider %q", cfg.Provider)
+		return result
+	}
+
+	if err := verifier(cfg, r, body); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Valid = true
+	return result
+}
+
+func (cfg *SignatureConfig) tolerance() time.Duration {
+	if cfg.ToleranceSeconds > 0 {
+		return time.Duration(cfg.ToleranceSeconds) * time.Second
+	}
+	return defaultSignatureTolerance
+}
+
+func (cfg *SignatureConfig) secrets() []string {
+	return append([]string{cfg.Secret}, cfg.Secrets...)
+}
+
+func computeHMAC(h func() hash.Hash, secret string, parts ...[]byte) []byte {
+	mac := hmac.New(h, []byte(secret))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+// checkTimestamp validates a unix timestamp header against the configured tolerance
+func checkTimestamp(value string, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", value)
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp outside tolerance (%s)", age.Round(time.Second))
+	}
+	return nil
+}
+
+// GitHub: X-Hub-Signature-256 = "sha256=" + hex(HMAC-SHA256(secret, body))
+func verifyGitHubSignature(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	header := r.Header.Get("X-Hub-Signature-256")
+	if header == "" {
+		return fmt.Errorf("missing X-Hub-Signature-256 header")
+	}
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return fmt.Errorf("X-Hub-Signature-256 must start with sha256=")
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("X-Hub-Signature-256 is not hex")
+	}
+	if !hmac.Equal(got, computeHMAC(sha256.New, cfg.Secret, body)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Stripe: Stripe-Signature = "t=<ts>,v1=<hex>[,v1=<hex>...]" over "<ts>.<body>"
+func verifyStripeSignature(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	header := r.Header.Get("Stripe-Signature")
+	if header == "" {
+		return fmt.Errorf("missing Stripe-Signature header")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, item := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" {
+		return fmt.Errorf("Stripe-Signature has no timestamp")
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("Stripe-Signature has no v1 signatures")
+	}
+
+	expected := computeHMAC(sha256.New, cfg.Secret, []byte(timestamp), []byte("."), body)
+	matched := false
+	for _, sig := range signatures {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, expected) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no v1 signature matches")
+	}
+	return checkTimestamp(timestamp, cfg.tolerance())
+}
+
+// Slack: X-Slack-Signature = "v0=" + hex(HMAC-SHA256(secret, "v0:<ts>:<body>"))
+func verifySlackSignature(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if timestamp == "" {
+		return fmt.Errorf("missing X-Slack-Request-Timestamp header")
+	}
+	header := r.Header.Get("X-Slack-Signature")
+	sig, ok := strings.CutPrefix(header, "v0=")
+	if !ok {
+		return fmt.Errorf("missing or malformed X-Slack-Signature header")
+	}
+	if err := checkTimestamp(timestamp, cfg.tolerance()); err != nil {
+		return err
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("X-Slack-Signature is not hex")
+	}
+	expected := computeHMAC(sha256.New, cfg.Secret, []byte("v0:"+timestamp+":"), body)
+	if !hmac.Equal(got, expected) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Shopify: X-Shopify-Hmac-Sha256 = base64(HMAC-SHA256(secret, body))
+func verifyShopifySignature(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	header := r.Header.Get("X-Shopify-Hmac-Sha256")
+	if header == "" {
+		return fmt.Errorf("missing X-Shopify-Hmac-Sha256 header")
+	}
+	got, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("X-Shopify-Hmac-Sha256 is not base64")
+	}
+	if !hmac.Equal(got, computeHMAC(sha256.New, cfg.Secret, body)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Twilio: X-Twilio-Signature = base64(HMAC-SHA1(authToken, url + sorted form key/value pairs)).
+// JSON bodies are instead covered by a bodySHA256 query parameter in the signed URL.
+func verifyTwilioSignature(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	header := r.Header.Get("X-Twilio-Signature")
+	if header == "" {
+		return fmt.Errorf("missing X-Twilio-Signature header")
+	}
+	got, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("X-Twilio-Signature is not base64")
+	}
+
+	signedURL := cfg.URL
+	if signedURL == "" {
+		signedURL = requestURL(r)
+	} else if r.URL.RawQuery != "" && !strings.Contains(signedURL, "?") {
+		signedURL += "?" + r.URL.RawQuery
+	}
+
+	var payload strings.Builder
+	payload.WriteString(signedURL)
+
+	if bodyHash := r.URL.Query().Get("bodySHA256"); bodyHash != "" {
+		sum := sha256.Sum256(body)
+		if !strings.EqualFold(bodyHash, hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("bodySHA256 does not match request body")
+		}
+	} else if r.Method == "POST" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		params, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Errorf("invalid form body: %v", err)
+		}
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			values := append([]string(nil), params[key]...)
+			sort.Strings(values)
+			for _, value := range values {
+				payload.WriteString(key)
+				payload.WriteString(value)
+			}
+		}
+	}
+
+	if !hmac.Equal(got, computeHMAC(sha1.New, cfg.Secret, []byte(payload.String()))) {
+		return fmt.Errorf("signature mismatch for %s", signedURL)
+	}
+	return nil
+}
+
+// requestURL reconstructs the absolute URL the sender used, honouring reverse proxy headers
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+	return scheme + "://" + host + r.URL.RequestURI()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(t *testing.T, scheme, secret string, body []byte, now time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/webhook/test", strings.NewReader(string(body)))
+	if err := signRequest(&SigningConfig{Scheme: scheme, Secret: secret}, r.Header, body, "msg_1", now); err != nil {
+		t.Fatalf("signRequest(%s): %v", scheme, err)
+	}
+	return r
+}
+
+func TestVerifiersAcceptSignRequest(t *testing.T) {
+	body := []byte(`{"event":"ping","id":42}`)
+
+	tests := []struct {
+		scheme string
+		secret string
+	}{
+		{"github", "gh-secret"},
+		{"stripe", "whsec_stripe"},
+		{"slack", "slack-signing-secret"},
+		{"shopify", "shopify-secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			cfg := &SignatureConfig{Provider: tt.scheme, Secret: tt.secret}
+
+			r := signedRequest(t, tt.scheme, tt.secret, body, time.Now())
+			if result := verifySignature(cfg, "test", r, body); !result.Valid {
+				t.Fatalf("signature not accepted: %s", result.Error)
+			}
+
+			r = signedRequest(t, tt.scheme, tt.secret, body, time.Now())
+			if result := verifySignature(cfg, "test", r, []byte(`{"event":"ping","id":43}`)); result.Valid {
+				t.Error("tampered body accepted")
+			}
+
+			r = signedRequest(t, tt.scheme, tt.secret+"x", body, time.Now())
+			if result := verifySignature(cfg, "test", r, body); result.Valid {
+				t.Error("signature made with another secret accepted")
+			}
+		})
+	}
+}
+
+func TestVerifiersRejectStaleTimestamps(t *testing.T) {
+	body := []byte(`{}`)
+	for _, scheme := range []string{"stripe", "slack"} {
+		t.Run(scheme, func(t *testing.T) {
+			cfg := &SignatureConfig{Provider: scheme, Secret: "secret", ToleranceSeconds: 60}
+			r := signedRequest(t, scheme, cfg.Secret, body, time.Now().Add(-2*time.Minute))
+			result := verifySignature(cfg, "test", r, body)
+			if result.Valid || !strings.Contains(result.Error, "tolerance") {
+				t.Errorf("got valid=%v error=%q, want a tolerance error", result.Valid, result.Error)
+			}
+		})
+	}
+}
+
+func TestTwilioSignature(t *testing.T) {
+	const token = "twilio-auth-token"
+	const signedURL = "https://example.com/webhook/sms"
+	body := "To=%2B15005550006&From=%2B15551234567&Body=hi&Body=again"
+
+	mac := hmac.New(sha1.New, []byte(token))
+	mac.Write([]byte(signedURL + "Bodyagain" + "Bodyhi" + "From+15551234567" + "To+15005550006"))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"signed form", body, true},
+		{"changed parameter", strings.Replace(body, "hi", "bye", 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook/sms", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("X-Twilio-Signature", signature)
+
+			cfg := &SignatureConfig{Provider: "twilio", Secret: token, URL: signedURL}
+			if result := verifySignature(cfg, "test", r, []byte(tt.body)); result.Valid != tt.valid {
+				t.Errorf("valid = %v, want %v (%s)", result.Valid, tt.valid, result.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignRequestHMAC(t *testing.T) {
+	body := []byte(`{"ok":true}`)
+	sum := func(h func() hash.Hash) []byte {
+		mac := hmac.New(h, []byte("secret"))
+		mac.Write(body)
+		return mac.Sum(nil)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    SigningConfig
+		header string
+		want   string
+	}{
+		{
+			name:   "defaults",
+			cfg:    SigningConfig{Scheme: "hmac", Secret: "secret"},
+			header: "X-Signature",
+			want:   hex.EncodeToString(sum(sha256.New)),
+		},
+		{
+			name:   "sha1 with prefix",
+			cfg:    SigningConfig{Scheme: "hmac", Secret: "secret", Algorithm: "sha1", Prefix: "sha1="},
+			header: "X-Signature",
+			want:   "sha1=" + hex.EncodeToString(sum(sha1.New)),
+		},
+		{
+			name:   "sha512 base64 in a custom header",
+			cfg:    SigningConfig{Scheme: "hmac", Secret: "secret", Algorithm: "sha512", Encoding: "base64", Header: "X-Hook-Sig"},
+			header: "X-Hook-Sig",
+			want:   base64.StdEncoding.EncodeToString(sum(sha512.New)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			header := http.Header{}
+			if err := signRequest(&tt.cfg, header, body, "msg_1", time.Now()); err != nil {
+				t.Fatalf("signRequest: %v", err)
+			}
+			if got := header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
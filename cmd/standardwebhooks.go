@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Standard Webhooks (https://www.standardwebhooks.com):
+//
+//	webhook-signature = space separated "v1,<base64 HMAC-SHA256>" entries
+//	signed content    = "<webhook-id>.<webhook-timestamp>.<body>"
+//
+// Secrets are "whsec_" followed by the base64 key. Every configured secret is
+// tried so that a sender can rotate keys without failing verification.
+func verifyStandardWebhook(cfg *SignatureConfig, r *http.Request, body []byte) error {
+	id := r.Header.Get("webhook-id")
+	timestamp := r.Header.Get("webhook-timestamp")
+	header := r.Header.Get("webhook-signature")
+	if id == "" || timestamp == "" || header == "" {
+		return fmt.Errorf("missing webhook-id, webhook-timestamp or webhook-signature header")
+	}
+	if err := checkTimestamp(timestamp, cfg.tolerance()); err != nil {
+		return err
+	}
+
+	var signatures [][]byte
+	for _, entry := range strings.Fields(header) {
+		version, sig, ok := strings.Cut(entry, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(sig)
+		if err == nil {
+			signatures = append(signatures, decoded)
+		}
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("webhook-signature has no v1 signatures")
+	}
+
+	signed := []byte(id + "." + timestamp + ".")
+	for _, secret := range cfg.secrets() {
+		expected := computeHMAC(sha256.New, string(standardWebhookKey(secret)), signed, body)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no v1 signature matches any configured secret")
+}
+
+const flagDuplicate = "duplicate"
+
+// standardWebhookKey decodes a "whsec_" secret, falling back to the raw bytes
+func standardWebhookKey(secret string) []byte {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return []byte(secret)
+	}
+	return key
+}
+
+// findRequestByHeader returns the ID of the earliest captured request on the
+// endpoint carrying the given header value, or "" if there is none
+func findRequestByHeader(endpoint, header, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	requestsMux.RLock()
+	defer requestsMux.RUnlock()
+
+	// History is newest first, so the last match is the first delivery
+	found := ""
+	for _, request := range requests {
+		if request.Endpoint != endpoint {
+			continue
+		}
+		for _, v := range http.Header(request.Headers).Values(header) {
+			if v == value {
+				found = request.ID
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStandardWebhookSecret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+
+// withHistory replaces the capture history for the length of a test
+func withHistory(t *testing.T, history []WebhookRequest) {
+	t.Helper()
+	requestsMux.Lock()
+	saved := requests
+	requests = history
+	requestsMux.Unlock()
+	t.Cleanup(func() {
+		requestsMux.Lock()
+		requests = saved
+		requestsMux.Unlock()
+	})
+}
+
+func standardWebhookRequest(t *testing.T, secret string, body []byte, now time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/webhook/test", strings.NewReader(string(body)))
+	if err := signRequest(&SigningConfig{Scheme: "standard-webhooks", Secret: secret}, r.Header, body, "msg_1", now); err != nil {
+		t.Fatalf("signRequest: %v", err)
+	}
+	return r
+}
+
+func TestVerifyStandardWebhook(t *testing.T) {
+	body := []byte(`{"type":"invoice.paid"}`)
+	rotated := "whsec_" + base64.StdEncoding.EncodeToString([]byte("rotated key"))
+
+	tests := []struct {
+		name      string
+		cfg       SignatureConfig
+		signWith  string
+		signedAt  time.Time
+		body      []byte
+		wantValid bool
+	}{
+		{"signed", SignatureConfig{Secret: testStandardWebhookSecret}, testStandardWebhookSecret, time.Now(), body, true},
+		{"tampered body", SignatureConfig{Secret: testStandardWebhookSecret}, testStandardWebhookSecret, time.Now(), []byte(`{}`), false},
+		{"other secret", SignatureConfig{Secret: testStandardWebhookSecret}, rotated, time.Now(), body, false},
+		{"rotated secret", SignatureConfig{Secret: testStandardWebhookSecret, Secrets: []string{rotated}}, rotated, time.Now(), body, true},
+		{"stale timestamp", SignatureConfig{Secret: testStandardWebhookSecret, ToleranceSeconds: 60}, testStandardWebhookSecret, time.Now().Add(-2 * time.Minute), body, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHistory(t, nil)
+			tt.cfg.Provider = "standard-webhooks"
+			r := standardWebhookRequest(t, tt.signWith, body, tt.signedAt)
+
+			result := verifySignature(&tt.cfg, "test", r, tt.body)
+			if result.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v (%s)", result.Valid, tt.wantValid, result.Error)
+			}
+			if result.MessageID != "msg_1" {
+				t.Errorf("messageId = %q, want msg_1", result.MessageID)
+			}
+		})
+	}
+}
+
+func TestStandardWebhookDuplicateOfFirstDelivery(t *testing.T) {
+	delivery := func(id, endpoint, messageID string) WebhookRequest {
+		return WebhookRequest{ID: id, Endpoint: endpoint, Headers: map[string][]string{"Webhook-Id": {messageID}}}
+	}
+	// History is newest first
+	withHistory(t, []WebhookRequest{
+		delivery("req-4", "test", "msg_1"),
+		delivery("req-3", "other", "msg_1"),
+		delivery("req-2", "test", "msg_1"),
+		delivery("req-1", "test", "msg_0"),
+	})
+
+	cfg := &SignatureConfig{Provider: "standard-webhooks", Secret: testStandardWebhookSecret}
+	r := standardWebhookRequest(t, cfg.Secret, []byte(`{}`), time.Now())
+	if result := verifySignature(cfg, "test", r, []byte(`{}`)); result.DuplicateOf != "req-2" {
+		t.Errorf("duplicateOf = %q, want the first delivery req-2", result.DuplicateOf)
+	}
+	if result := verifySignature(cfg, "fresh", r, []byte(`{}`)); result.DuplicateOf != "" {
+		t.Errorf("duplicateOf = %q on an endpoint without earlier deliveries", result.DuplicateOf)
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
 	"sync"
 	"time"
 
@@ -20,6 +25,7 @@ import (
 
 type WebhookRequest struct {
 	ID          string              `json:"id"`
+	Seq         uint64              `json:"seq"` // capture order, used by WebSocket clients to resume
 	Timestamp   time.Time           `json:"timestamp"`
 	Method      string              `json:"method"`
 	Headers     map[string][]string `json:"headers"`
@@ -29,14 +35,37 @@ type WebhookRequest struct {
 	Files       []FileInfo          `json:"files,omitempty"`
 	RemoteAddr  string              `json:"remoteAddr"`
 	ContentType string              `json:"contentType"`
+	Endpoint    string              `json:"endpoint,omitempty"`
+	Provider    string              `json:"provider,omitempty"`  // sender worked out by the classifier
+	EventType   string              `json:"eventType,omitempty"` // provider's event type
+	Flags       []string            `json:"flags,omitempty"`
+	Auth        *AuthResult         `json:"auth,omitempty"`
+	Signature   *SignatureResult    `json:"signature,omitempty"`
+	JWT         *JWTResult          `json:"jwt,omitempty"`
+	Breakpoint  *BreakpointState    `json:"breakpoint,omitempty"`
+	Handshake   *HandshakeResult    `json:"handshake,omitempty"`
+	Envelope    *EnvelopeInfo       `json:"envelope,omitempty"`    // unwrapped SNS, Pub/Sub or Event Grid message
+	CloudEvents []CloudEvent        `json:"cloudEvents,omitempty"` // one event, or several for a batch
+	XML         *XMLDocument        `json:"xml,omitempty"`         // parsed XML or SOAP body
+	Encoding    *EncodingInfo       `json:"encoding,omitempty"`    // Content-Encoding that was undone
+	Violations  []string            `json:"violations,omitempty"`  // multipart policy rules the request broke
+	Upstream    *UpstreamResponse   `json:"upstream,omitempty"`    // response relayed to the sender
+	Upstreams   []UpstreamResponse  `json:"upstreams,omitempty"`   // every fan-out target's outcome
+	DerivedFrom string              `json:"derivedFrom,omitempty"` // source capture of an edited replay
+	Replays     []ReplayAttempt     `json:"replays,omitempty"`
+	RawBody     []byte              `json:"-"` // body bytes after Content-Encoding decoding, kept for replay (not multipart)
 }
 
 type FileInfo struct {
-	Filename    string `json:"filename"`
-	ContentType string `json:"content_type"`
-	Size        int64  `json:"size"`
-	Content     string `json:"content,omitempty"`
-	DownloadURL string `json:"downloadURL,omitempty"`
+	Field       string              `json:"field,omitempty"`
+	Index       int                 `json:"index"` // position of the part in the multipart body
+	Filename    string              `json:"filename"`
+	ContentType string              `json:"content_type"`
+	Size        int64               `json:"size"`
+	Content     string              `json:"content,omitempty"`
+	DownloadURL string              `json:"downloadURL,omitempty"`
+	Headers     map[string][]string `json:"headers,omitempty"`  // the part's own headers
+	StoredAs    string              `json:"storedAs,omitempty"` // file storage key, usable as storedFile
 }
 
 type WebhookResponse struct {
@@ -47,14 +76,10 @@ type WebhookResponse struct {
 
 // ThoughtSpot webhook response structure
 type ThoughtSpotWebhookData struct {
-	Users []struct {
-		DisplayName string `json:"displayName"`
-		Email       string `json:"email"`
-		UserID      string `json:"userId"`
-	} `json:"users"`
-	SchemaVersion                      string `json:"schemaVersion"`
-	SchemaType                         string `json:"schemaType"`
-	NotificationType                   string `json:"notificationType"`
+	Users                              []ThoughtSpotUser `json:"users"`
+	SchemaVersion                      string            `json:"schemaVersion"`
+	SchemaType                         string            `json:"schemaType"`
+	NotificationType                   string            `json:"notificationType"`
 	ScheduledReportWebhookNotification struct {
 		ReportID     string `json:"reportId"`
 		ReportName   string `json:"reportName"`
@@ -75,44 +100,53 @@ type ThoughtSpotWebhookData struct {
 			PinboardName string `json:"pinboardName"`
 			ReportURL    string `json:"reportUrl"`
 		} `json:"reportMetadata"`
-		Attachments []struct {
-			AttachmentID string `json:"attachmentId"`
-			FileName     string `json:"fileName"`
-			FileSize     int    `json:"fileSize"`
-			ContentType  string `json:"contentType"`
-			Disposition  string `json:"disposition"`
-			Checksum     string `json:"checksum"`
-			PartNumber   int    `json:"partNumber"`
-		} `json:"attachments"`
+		Attachments []ThoughtSpotAttachment `json:"attachments"`
 	} `json:"scheduledReportWebhookNotification"`
 }
 
+type ThoughtSpotUser struct {
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+	UserID      string `json:"userId"`
+}
+
+type ThoughtSpotAttachment struct {
+	AttachmentID string `json:"attachmentId"`
+	FileName     string `json:"fileName"`
+	FileSize     int    `json:"fileSize"`
+	ContentType  string `json:"contentType"`
+	Disposition  string `json:"disposition"`
+	Checksum     string `json:"checksum"`
+	PartNumber   int    `json:"partNumber"`
+}
+
 // Global state for storing requests and WebSocket connections
 var (
 	requests       []WebhookRequest
+	requestSeq     uint64 // last sequence number assigned, guarded by requestsMux
 	requestsMux    sync.RWMutex
-	clients        = make(map[*websocket.Conn]*sync.Mutex) // Store client with its own mutex
+	clients        = make(map[*websocket.Conn]*wsClient) // Store client with its send queue
 	clientsMux     sync.RWMutex
-	fileStorage    = make(map[string][]byte) // Store file content by filename
+	fileStorage    = make(map[string][]byte) // Store file content by storage key
 	fileStorageMux sync.RWMutex
 	upgrader       = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins for development
-		},
+		CheckOrigin: checkOrigin,
 	}
 )
 
-// Helper function to get keys from a map
-func getKeys(m map[string][]string) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	return keys
+// wsClient is the single writer of one WebSocket connection. Messages are queued
+// in the order they are broadcast, so captures reach every client in seq order.
+type wsClient struct {
+	endpoint string           // only receive captures for this endpoint when set
+	send     chan interface{} // closed when the client is removed
 }
 
-// Helper function to get keys from file headers map
-func getFileKeys(m map[string][]*multipart.FileHeader) []string {
+// Messages a client may fall behind by before it is disconnected; room for the
+// whole history plus the connected event
+const wsClientQueue = 256
+
+// Helper function to get keys from a map
+func getKeys(m map[string][]string) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
@@ -124,23 +158,69 @@ func main() {
 	// Set up logging to console only for containerized environments
 	log.SetOutput(os.Stdout)
 
+	if len(os.Args) > 1 && os.Args[1] == "relay" {
+		runRelay(os.Args[2:])
+		return
+	}
+
 	log.Printf("=== Webhook Server Starting ===")
 	log.Printf("Logging to console")
 
+	// Load optional endpoint configuration
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		if err := loadConfig(configFile); err != nil {
+			log.Fatal("Failed to load config: ", err)
+		}
+		log.Printf("Loaded configuration from %s", configFile)
+	}
+
+	// Environment overrides for admin protection
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		adminConfig.Token = token
+	}
+	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
+		adminConfig.AllowedOrigins = strings.Split(origins, ",")
+	}
+	if adminConfig.Token == "" {
+		log.Printf("WARNING: ADMIN_TOKEN not set, UI and /api/* are open to anyone")
+	}
+
 	// Create a new mux to handle routing properly
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/", handleRoot)
+	// Public capture surface
 	mux.HandleFunc("/webhook", handleWebhook)
+	mux.HandleFunc("/webhook/", handleWebhook)
 	mux.HandleFunc("/webhook/thoughtspot", handleThoughtSpotWebhook)
 	mux.HandleFunc("/health", handleHealth)
-	mux.HandleFunc("/api/requests", handleAPIRequests)
-	mux.HandleFunc("/api/clear", handleClearRequests)
-	mux.HandleFunc("/ws", handleWebSocket)
-	mux.HandleFunc("/download/", handleFileDownload)
 	mux.HandleFunc("/test", handleTest)
 	mux.HandleFunc("/ping", handlePing)
 
+	// Admin surface, optionally served on its own port
+	adminMux := mux
+	adminPort := os.Getenv("ADMIN_PORT")
+	if adminPort != "" {
+		adminMux = http.NewServeMux()
+		adminMux.HandleFunc("/health", handleHealth)
+	}
+
+	adminMux.HandleFunc("/", requireAdmin(handleRoot))
+	adminMux.HandleFunc("/api/requests", requireAdmin(handleAPIRequests))
+	adminMux.HandleFunc("/api/requests/", requireAdmin(handleRequestByID))
+	adminMux.HandleFunc("/api/clear", requireAdmin(handleClearRequests))
+	adminMux.HandleFunc("/api/endpoints", requireAdmin(handleAPIEndpoints))
+	adminMux.HandleFunc("/api/breakpoints", requireAdmin(handleBreakpoints))
+	adminMux.HandleFunc("/api/breakpoints/", requireAdmin(handleBreakpoints))
+	adminMux.HandleFunc("/api/replay-jobs", requireAdmin(handleReplayJobs))
+	adminMux.HandleFunc("/api/replay-jobs/", requireAdmin(handleReplayJobs))
+	adminMux.HandleFunc("/api/deliveries", requireAdmin(handleDeliveries))
+	adminMux.HandleFunc("/api/deliveries/", requireAdmin(handleDeliveries))
+	adminMux.HandleFunc("/api/schedules", requireAdmin(handleSchedules))
+	adminMux.HandleFunc("/api/schedules/", requireAdmin(handleSchedules))
+	adminMux.HandleFunc("/api/emulators/", requireAdmin(handleEmulators))
+	adminMux.HandleFunc("/ws", requireAdmin(handleWebSocket))
+	adminMux.HandleFunc("/download/", requireAdmin(handleFileDownload))
+
 	// Get port from environment variable or default to 8080
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -153,10 +233,25 @@ func main() {
 
 	log.Printf("Starting webhook test server on port %s", port)
 	log.Printf("Webhook endpoint: http://0.0.0.0%s/webhook", port)
+	log.Printf("Named webhook endpoints: http://0.0.0.0%s/webhook/{name}", port)
 	log.Printf("ThoughtSpot webhook endpoint: http://0.0.0.0%s/webhook/thoughtspot", port)
-	log.Printf("Web UI: http://0.0.0.0%s", port)
 	log.Printf("Health check: http://0.0.0.0%s/health", port)
 
+	if adminPort != "" {
+		adminServer := &http.Server{
+			Addr:    "0.0.0.0:" + adminPort,
+			Handler: adminMux,
+		}
+		log.Printf("Web UI and admin API: http://0.0.0.0:%s", adminPort)
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil {
+				log.Fatal("Admin server failed to start:", err)
+			}
+		}()
+	} else {
+		log.Printf("Web UI: http://0.0.0.0%s", port)
+	}
+
 	log.Printf("Server listening on %s", port)
 	log.Printf("Ready to accept connections...")
 
@@ -188,14 +283,60 @@ func handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set appropriate headers for download
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	// Set appropriate headers for download; keys of captured files end in the file name
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", path.Base(filename)))
 	w.Header().Set("Content-Type", getContentType(filename))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileContent)))
 
 	w.Write(fileContent)
 }
 
+// deleteCaptureFiles removes the stored files of captures leaving history, which
+// are kept under keys starting with the capture ID
+func deleteCaptureFiles(captures []WebhookRequest) {
+	if len(captures) == 0 {
+		return
+	}
+	prefixes := make([]string, len(captures))
+	for i, capture := range captures {
+		prefixes[i] = capture.ID + "/"
+	}
+
+	fileStorageMux.Lock()
+	defer fileStorageMux.Unlock()
+	for key := range fileStorage {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(fileStorage, key)
+				break
+			}
+		}
+	}
+}
+
+// storedFileKey names a file's bytes in file storage, unique to the capture and part
+func storedFileKey(requestID, part, filename string) string {
+	return requestID + "/" + part + "/" + filename
+}
+
+// downloadURL escapes each segment of a file storage key
+func downloadURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/download/" + strings.Join(segments, "/")
+}
+
+// storageKey is where the file's bytes are kept; files stored before keys were
+// unique use their file name
+func (file FileInfo) storageKey() string {
+	if file.StoredAs != "" {
+		return file.StoredAs
+	}
+	return file.Filename
+}
+
 func getContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 	switch ext {
@@ -239,40 +380,73 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	// Register client
+	// ?endpoint= subscribes to one endpoint; ?since=<seq> resumes after a sequence
+	// number (oldest first) and ?since=latest skips history
+	query := r.URL.Query()
+	client := &wsClient{endpoint: query.Get("endpoint"), send: make(chan interface{}, wsClientQueue)}
+	since := query.Get("since")
+
+	// Register the client and queue the history while captures are held back, so
+	// every capture is either in the history or broadcast after it
+	requestsMux.RLock()
 	clientsMux.Lock()
-	clients[conn] = &sync.Mutex{}
+	clients[conn] = client
 	clientsMux.Unlock()
 
-	// Send existing requests to new client
-	requestsMux.RLock()
-	existingRequests := make([]WebhookRequest, len(requests))
-	copy(existingRequests, requests)
-	requestsMux.RUnlock()
+	existingRequests := requests
+	currentSeq := requestSeq
 
-	clientMux := clients[conn]
-	for _, request := range existingRequests {
-		clientMux.Lock()
-		if err := conn.WriteJSON(request); err != nil {
-			log.Printf("Error sending existing request to client: %v", err)
-			clientMux.Unlock()
-			break
+	var history []WebhookRequest
+	if since == "" {
+		for _, request := range existingRequests {
+			if client.accepts(request.Endpoint) {
+				history = append(history, request)
+			}
+		}
+	} else if since != "latest" {
+		after, _ := strconv.ParseUint(since, 10, 64)
+		for i := len(existingRequests) - 1; i >= 0; i-- {
+			if request := existingRequests[i]; request.Seq > after && client.accepts(request.Endpoint) {
+				history = append(history, request)
+			}
 		}
-		clientMux.Unlock()
 	}
 
+	for _, request := range history {
+		client.send <- request
+	}
+	if since != "" {
+		client.send <- map[string]interface{}{"event": "connected", "seq": currentSeq}
+	}
+	requestsMux.RUnlock()
+
+	go client.writeLoop(conn)
+
 	// Keep connection alive and handle disconnection
 	for {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
 			clientsMux.Lock()
 			delete(clients, conn)
+			close(client.send)
 			clientsMux.Unlock()
 			break
 		}
 	}
 }
 
+// writeLoop writes queued messages until the client is removed or a write fails
+func (c *wsClient) writeLoop(conn *websocket.Conn) {
+	for message := range c.send {
+		if err := conn.WriteJSON(message); err != nil {
+			log.Printf("Error broadcasting to client: %v", err)
+			// Closing ends the read loop, which removes the client
+			conn.Close()
+			return
+		}
+	}
+}
+
 func handleAPIRequests(w http.ResponseWriter, r *http.Request) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -285,12 +459,16 @@ func handleAPIRequests(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	requestsMux.RLock()
-	defer requestsMux.RUnlock()
+	filter := filterFromQuery(r.URL.Query())
+	if err := filter.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matched := filterRequests(filter)
 
 	response := map[string]interface{}{
-		"requests": requests,
-		"count":    len(requests),
+		"requests": matched,
+		"count":    len(matched),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -317,13 +495,12 @@ func handleClearRequests(w http.ResponseWriter, r *http.Request) {
 
 	// Clear all requests from memory
 	requestsMux.Lock()
+	cleared := requests
 	requests = []WebhookRequest{}
 	requestsMux.Unlock()
 
-	// Clear file storage as well
-	fileStorageMux.Lock()
-	fileStorage = make(map[string][]byte)
-	fileStorageMux.Unlock()
+	// Clear their files as well; held breakpoint captures keep theirs
+	deleteCaptureFiles(cleared)
 
 	log.Printf("Clearing all requests from server memory")
 
@@ -343,45 +520,74 @@ func broadcastRequest(request WebhookRequest) {
 	log.Printf("Broadcasting request - Body: %+v", request.Body)
 	log.Printf("Broadcasting request - Files: %+v", request.Files)
 
-	clientsMux.RLock()
-	// Create a copy of clients to avoid holding the lock during writes
-	clientsCopy := make(map[*websocket.Conn]*sync.Mutex)
-	for client, clientMux := range clients {
-		clientsCopy[client] = clientMux
-	}
-	clientsMux.RUnlock()
+	broadcast(request.Endpoint, request)
+}
 
-	for client, clientMux := range clientsCopy {
-		clientMux.Lock()
-		if err := client.WriteJSON(request); err != nil {
-			log.Printf("Error broadcasting to client: %v", err)
-			client.Close()
-			clientMux.Unlock()
+// broadcastEvent sends any JSON message to every WebSocket client. Messages other
+// than captured requests carry an "event" field so clients can tell them apart.
+func broadcastEvent(event interface{}) {
+	broadcast("", event)
+}
 
-			// Remove client from the main map
-			clientsMux.Lock()
-			delete(clients, client)
-			clientsMux.Unlock()
-		} else {
-			clientMux.Unlock()
+// broadcast queues event for each client without waiting on slow connections
+func broadcast(endpoint string, event interface{}) {
+	clientsMux.RLock()
+	defer clientsMux.RUnlock()
+
+	for conn, client := range clients {
+		if endpoint != "" && !client.accepts(endpoint) {
+			continue
+		}
+		select {
+		case client.send <- event:
+		default:
+			log.Printf("WebSocket client %s is not keeping up, disconnecting", conn.RemoteAddr())
+			conn.Close()
 		}
 	}
 }
 
+func (c *wsClient) accepts(endpoint string) bool {
+	return c.endpoint == "" || c.endpoint == endpoint
+}
+
 func addRequest(request WebhookRequest) {
+	if request.Provider == "" {
+		classifyRequest(&request)
+	}
+
 	requestsMux.Lock()
 	defer requestsMux.Unlock()
 
+	requestSeq++
+	request.Seq = requestSeq
+
 	// Add request to the beginning of the slice
 	requests = append([]WebhookRequest{request}, requests...)
 
 	// Keep only last 100 requests
 	if len(requests) > 100 {
+		deleteCaptureFiles(requests[100:])
 		requests = requests[:100]
 	}
 
-	// Broadcast to WebSocket clients
-	go broadcastRequest(request)
+	// Broadcast to WebSocket clients while still holding the lock, so they are
+	// queued in seq order
+	broadcastRequest(request)
+}
+
+// updateRequest applies update to a stored request if it is still in history
+func updateRequest(id string, update func(*WebhookRequest)) bool {
+	requestsMux.Lock()
+	defer requestsMux.Unlock()
+
+	for i := range requests {
+		if requests[i].ID == id {
+			update(&requests[i])
+			return true
+		}
+	}
+	return false
 }
 
 func handleWebhook(w http.ResponseWriter, r *http.Request) {
@@ -415,6 +621,7 @@ func handleWebhook(w http.ResponseWriter, r *http.Request) {
 		Query:       r.URL.Query(),
 		RemoteAddr:  r.RemoteAddr,
 		ContentType: r.Header.Get("Content-Type"),
+		Endpoint:    endpointName(r.URL.Path),
 	}
 
 	// Log headers
@@ -425,27 +632,123 @@ func handleWebhook(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Read the raw body once so signatures can be checked against the exact bytes sent
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Error reading body", http.StatusBadRequest)
+		return
+	}
+	r.Body.Close()
+
+	// Decompress gzip and deflate bodies; forwarding still sends the bytes as received
+	received := body
+	if contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding != "" {
+		decoded, info, err := decodeContentEncoding(contentEncoding, body)
+		webhookReq.Encoding = info
+		if info != nil {
+			log.Printf("Content-Encoding %s: %d bytes -> %d bytes %s", contentEncoding, info.CompressedSize, info.DecompressedSize, info.Error)
+		}
+		if errors.Is(err, errDecompressionLimit) {
+			webhookReq.RawBody = body
+			// Captured before handshake, auth and signature checks ever ran
+			webhookReq.Flags = append(webhookReq.Flags, flagDecompressionLimit, flagUnauthenticated)
+			addRequest(webhookReq)
+			http.Error(w, "Decompressed body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		body = decoded
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	webhookReq.RawBody = body
+
+	endpoint, _ := getEndpoint(webhookReq.Endpoint)
+	log.Printf("Endpoint: %s", webhookReq.Endpoint)
+
+	// Answer provider URL-verification handshakes first, as they are usually sent without credentials
+	if endpoint.Handshake != nil {
+		if result, ok := answerHandshake(w, r, body, endpoint.Handshake); ok {
+			log.Printf("Handshake (%s): answered %d %s", result.Provider, result.Status, result.Error)
+			var jsonBody interface{}
+			if err := json.Unmarshal(body, &jsonBody); err == nil {
+				webhookReq.Body = jsonBody
+			} else if len(body) > 0 {
+				webhookReq.Body = string(body)
+			}
+			webhookReq.Handshake = &result
+			webhookReq.Flags = append(webhookReq.Flags, flagHandshake)
+			addRequest(webhookReq)
+			return
+		}
+	}
+
+	// Check endpoint credentials; rejected requests are still captured so senders can be debugged
+	if endpoint.Auth != nil {
+		result := checkAuth(endpoint.Auth, r)
+		webhookReq.Auth = &result
+		log.Printf("Auth (%s): valid=%v %s", result.Type, result.Valid, result.Error)
+
+		if !result.Valid {
+			webhookReq.Flags = append(webhookReq.Flags, flagUnauthorized)
+			addRequest(webhookReq)
+			if endpoint.Auth.Type == "basic" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", webhookReq.Endpoint))
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Verify provider signature if the endpoint has one configured
+	if endpoint.Signature != nil {
+		result := verifySignature(endpoint.Signature, webhookReq.Endpoint, r, body)
+		webhookReq.Signature = &result
+		log.Printf("Signature (%s): valid=%v %s", result.Provider, result.Valid, result.Error)
+		if result.DuplicateOf != "" {
+			log.Printf("Duplicate message %s, first captured as %s", result.MessageID, result.DuplicateOf)
+			webhookReq.Flags = append(webhookReq.Flags, flagDuplicate)
+		}
+
+		if !result.Valid && endpoint.Signature.Enforce {
+			addRequest(webhookReq)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Verify bearer JWT if the endpoint requires one
+	if endpoint.JWT != nil {
+		result := verifyJWT(endpoint.JWT, r)
+		webhookReq.JWT = &result
+		log.Printf("JWT (%s, kid %q): valid=%v %s", result.Algorithm, result.KeyID, result.Valid, result.Error)
+
+		if !result.Valid && endpoint.JWT.Enforce {
+			addRequest(webhookReq)
+			http.Error(w, "Invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Handle multipart form data
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "" && len(contentType) > 20 && strings.HasPrefix(contentType, "multipart/form-data") {
 		log.Printf("Processing multipart/form-data request...")
 		log.Printf("Content-Type header: %s", r.Header.Get("Content-Type"))
 
-		// Parse multipart form
-		err := r.ParseMultipartForm(32 << 20) // 32 MB max memory
+		// Read every part in order; files of any type are kept unless the endpoint has a policy
+		log.Printf("Parts:")
+		fields, files, err := readMultipartParts(requestID, contentType, body)
 		if err != nil {
 			log.Printf("Error parsing multipart form: %v", err)
 			http.Error(w, "Error parsing multipart form", http.StatusBadRequest)
 			return
 		}
-
-		log.Printf("MultipartForm.Value keys: %v", getKeys(r.MultipartForm.Value))
-		log.Printf("MultipartForm.File keys: %v", getFileKeys(r.MultipartForm.File))
+		log.Printf("Form value keys: %v", getKeys(fields))
 
 		// Process form values (JSON content)
 		formData := make(map[string]interface{})
 		log.Printf("Form Values:")
-		for key, values := range r.MultipartForm.Value {
+		for key, values := range fields {
 			if len(values) == 1 {
 				formData[key] = values[0]
 				log.Printf("  %s: %s", key, values[0])
@@ -456,90 +759,57 @@ func handleWebhook(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Add file field names to form data for UI matching
-		for fieldName := range r.MultipartForm.File {
-			formData[fieldName] = fmt.Sprintf("FILE_UPLOADED_%s", fieldName)
-			log.Printf("  %s: FILE_UPLOADED_%s", fieldName, fieldName)
+		for _, file := range files {
+			formData[file.Field] = fmt.Sprintf("FILE_UPLOADED_%s", file.Field)
 		}
 
 		webhookReq.Body = formData
 
-		// Process uploaded files - ensure only one file type per request
-		var files []FileInfo
-		log.Printf("Uploaded Files:")
-
-		// Track file types to ensure only one type per request
-		var firstContentType string
-		var hasFiles bool
-
-		for fieldName, fileHeaders := range r.MultipartForm.File {
-			for _, fileHeader := range fileHeaders {
-				log.Printf("  Field: %s", fieldName)
-				log.Printf("    Filename: %s", fileHeader.Filename)
-				log.Printf("    Content-Type: %s", fileHeader.Header.Get("Content-Type"))
-				log.Printf("    Size: %d bytes", fileHeader.Size)
-
-				contentType := fileHeader.Header.Get("Content-Type")
-
-				// Check if this is the first file or same type as first file
-				if !hasFiles {
-					firstContentType = contentType
-					hasFiles = true
-				} else if contentType != firstContentType {
-					log.Printf("    Skipping file - different type already exists (first: %s, current: %s)", firstContentType, contentType)
-					continue
-				}
-
-				fileInfo := FileInfo{
-					Filename:    fileHeader.Filename,
-					ContentType: contentType,
-					Size:        fileHeader.Size,
-					DownloadURL: fmt.Sprintf("/download/%s", fileHeader.Filename),
-				}
-
-				// Read file content
-				file, err := fileHeader.Open()
-				if err != nil {
-					log.Printf("    Error opening file: %v", err)
-					continue
-				}
-				defer file.Close()
+		// Files are kept in file storage, so the raw multipart body is not needed for replay
+		webhookReq.RawBody = nil
+		webhookReq.Files = files
+		log.Printf("Multipart processing completed - Files count: %d", len(files))
 
-				// Read entire file content
-				fileContent, err := io.ReadAll(file)
-				if err != nil {
-					log.Printf("    Error reading file: %v", err)
-					continue
+		// Apply the endpoint's file type policy, if it has one
+		if endpoint.Multipart != nil {
+			if violations := endpoint.Multipart.check(files); len(violations) > 0 {
+				log.Printf("Multipart policy violations: %v", violations)
+				webhookReq.Violations = violations
+				webhookReq.Flags = append(webhookReq.Flags, flagMultipartPolicy)
+				if endpoint.Multipart.Enforce {
+					addRequest(webhookReq)
+					http.Error(w, "Multipart policy violation: "+strings.Join(violations, "; "), http.StatusUnsupportedMediaType)
+					return
 				}
-
-				// Store file content for download
-				fileStorageMux.Lock()
-				fileStorage[fileHeader.Filename] = fileContent
-				fileStorageMux.Unlock()
-
-				log.Printf("    File processed successfully")
-				files = append(files, fileInfo)
 			}
 		}
-		webhookReq.Files = files
-		log.Printf("Multipart processing completed - Files count: %d", len(files))
 	} else {
 		log.Printf("Not a multipart request, processing as regular body")
 		log.Printf("Content-Type was: '%s'", contentType)
 		// Handle regular request body
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Printf("Error reading body: %v", err)
-			http.Error(w, "Error reading body", http.StatusBadRequest)
-			return
-		}
-		defer r.Body.Close()
-
 		if len(body) > 0 {
 			log.Printf("Body: %s", string(body))
 
-			// Try to parse as JSON for pretty printing
+			// Try to parse as form fields or JSON for pretty printing
 			var jsonBody interface{}
-			if err := json.Unmarshal(body, &jsonBody); err == nil {
+			if isFormURLEncoded(contentType) {
+				if formData, err := parseFormBody(body); err == nil {
+					log.Printf("Parsed form body: %v", formData)
+					webhookReq.Body = formData
+				} else {
+					log.Printf("Error parsing form body: %v", err)
+					webhookReq.Body = string(body)
+				}
+			} else if isXMLContentType(contentType) {
+				webhookReq.Body = string(body)
+				webhookReq.XML = parseXMLBody(r, body)
+				if webhookReq.XML.WellFormed {
+					log.Printf("XML body: root <%s>, body root <%s>, SOAPAction %q", webhookReq.XML.Root, webhookReq.XML.BodyRoot, webhookReq.XML.SOAPAction)
+				} else {
+					log.Printf("XML body is not well formed: %s", webhookReq.XML.Error)
+					webhookReq.Flags = append(webhookReq.Flags, flagInvalidXML)
+				}
+			} else if err := json.Unmarshal(body, &jsonBody); err == nil {
 				log.Printf("Parsed JSON Body: %v", jsonBody)
 				webhookReq.Body = jsonBody
 			} else {
@@ -562,10 +832,77 @@ func handleWebhook(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Final webhookReq.Body: %+v", webhookReq.Body)
 	log.Printf("Final webhookReq.Files: %+v", webhookReq.Files)
 
+	// Normalise CloudEvents so they look the same whichever mode they arrived in
+	if webhookReq.RawBody != nil {
+		if events := decodeCloudEvents(r, body); events != nil {
+			webhookReq.CloudEvents = events
+			log.Printf("CloudEvents (%s): %d event(s), first %s from %s", events[0].Mode, len(events), events[0].Type, events[0].Source)
+		}
+	}
+
+	// Unwrap cloud push envelopes into their inner payload
+	if endpoint.Envelope != nil && webhookReq.RawBody != nil {
+		if envelope := decodeEnvelope(endpoint.Envelope, r, body); envelope != nil {
+			webhookReq.Envelope = envelope
+			log.Printf("Envelope (%s): %s %s", envelope.Type, envelope.MessageType, envelope.MessageID)
+
+			if envelope.Signature != nil {
+				log.Printf("SNS signature: valid=%v %s", envelope.Signature.Valid, envelope.Signature.Error)
+				if !envelope.Signature.Valid && endpoint.Envelope.Enforce {
+					addRequest(webhookReq)
+					http.Error(w, "Invalid signature", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			// Event Grid validation is answered with the code it sent
+			if envelope.validationCode != "" {
+				response, _ := json.Marshal(map[string]string{"validationResponse": envelope.validationCode})
+				webhookReq.Handshake = &HandshakeResult{
+					Provider:  "eventgrid",
+					Challenge: envelope.validationCode,
+					Status:    http.StatusOK,
+					Response:  string(response),
+				}
+				webhookReq.Flags = append(webhookReq.Flags, flagHandshake)
+				addRequest(webhookReq)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(response)
+				return
+			}
+
+			// Confirm SNS subscriptions once the capture has been stored
+			if endpoint.Envelope.AutoConfirm && envelope.MessageType == "SubscriptionConfirmation" && envelope.subscribeURL != "" {
+				if refusal := endpoint.Envelope.confirmRefusal(envelope); refusal != "" {
+					log.Printf("Not confirming SNS subscription: %s", refusal)
+					envelope.Confirmation = &EnvelopeConfirmation{URL: envelope.subscribeURL, Error: "not confirmed: " + refusal}
+				} else {
+					subscribeURL := envelope.subscribeURL
+					defer func() { go confirmSNSSubscription(webhookReq.ID, subscribeURL) }()
+				}
+			}
+		}
+	}
+
+	// Breakpoint endpoints wait for someone to choose the response
+	if endpoint.Breakpoint != nil {
+		holdRequest(w, r, endpoint.Breakpoint, webhookReq)
+		return
+	}
+
+	// Forwarding endpoints answer with whatever the upstream returned
+	if endpoint.Forward != nil {
+		handleForward(w, r, received, endpoint.Forward, webhookReq)
+		return
+	}
+
 	// Add request to storage and broadcast
 	addRequest(webhookReq)
 
-	// Send response
+	writeWebhookResponse(w)
+}
+
+func writeWebhookResponse(w http.ResponseWriter) {
 	response := WebhookResponse{
 		Status:  "success",
 		Message: "Webhook received successfully",
@@ -612,6 +949,7 @@ func handleThoughtSpotWebhook(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err == nil && len(body) > 0 {
 		log.Printf("Request Body: %s", string(body))
+		webhookReq.RawBody = body // kept for replay and the relay
 
 		// Try to parse as JSON
 		var jsonBody interface{}
@@ -632,46 +970,9 @@ func handleThoughtSpotWebhook(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
 	nextMonth := now.AddDate(0, 1, 0)
 
-	webhookData := ThoughtSpotWebhookData{
-		Users: []struct {
-			DisplayName string `json:"displayName"`
-			Email       string `json:"email"`
-			UserID      string `json:"userId"`
-		}{
-			{
-				DisplayName: "John Doe",
-				Email:       "john.doe@thoughtspot.com",
-				UserID:      "user-12345",
-			},
-		},
-		SchemaVersion:    "v1",
-		SchemaType:       "SCHEDULED_REPORT",
-		NotificationType: "DELIVERY",
-	}
+	webhookData := sampleThoughtSpotData(now, nextMonth)
 
-	webhookData.ScheduledReportWebhookNotification.ReportID = "report-67890"
-	webhookData.ScheduledReportWebhookNotification.ReportName = "Monthly Sales Dashboard"
-	webhookData.ScheduledReportWebhookNotification.ScheduleInfo.ScheduleID = "schedule-abc123"
-	webhookData.ScheduledReportWebhookNotification.ScheduleInfo.ScheduleString = "monthly on 1st day at 9:00 AM"
-	webhookData.ScheduledReportWebhookNotification.ScheduleInfo.NextRunTime = nextMonth.Format(time.RFC3339)
-	webhookData.ScheduledReportWebhookNotification.ScheduleInfo.Timezone = "America/New_York"
-	webhookData.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryID = "delivery-xyz789"
-	webhookData.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryTime = now.Format(time.RFC3339)
-	webhookData.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryStatus = "SUCCESS"
-	webhookData.ScheduledReportWebhookNotification.DeliveryInfo.RecipientCount = 5
-	webhookData.ScheduledReportWebhookNotification.ReportMetadata.PinboardID = "22a8f618-0b4f-4401-92db-ba029ee13486"
-	webhookData.ScheduledReportWebhookNotification.ReportMetadata.PinboardName = "Sales Performance Dashboard"
-	webhookData.ScheduledReportWebhookNotification.ReportMetadata.ReportURL = "http://thoughtspot.company.com/?utm_source=scheduled_report&utm_medium=webhook/#/pinboard/22a8f618-0b4f-4401-92db-ba029ee13486"
-
-	webhookData.ScheduledReportWebhookNotification.Attachments = []struct {
-		AttachmentID string `json:"attachmentId"`
-		FileName     string `json:"fileName"`
-		FileSize     int    `json:"fileSize"`
-		ContentType  string `json:"contentType"`
-		Disposition  string `json:"disposition"`
-		Checksum     string `json:"checksum"`
-		PartNumber   int    `json:"partNumber"`
-	}{
+	webhookData.ScheduledReportWebhookNotification.Attachments = []ThoughtSpotAttachment{
 		{
 			AttachmentID: "att-001",
 			FileName:     fmt.Sprintf("Monthly_Sales_Dashboard_%s.pdf", now.Format("2006-01")),
@@ -723,8 +1024,47 @@ func handleThoughtSpotWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create a sample PDF content (base64 encoded)
-	samplePDF := `%PDF-1.4
+	// Encode PDF content as base64
+	encodedPDF := base64.StdEncoding.EncodeToString([]byte(sampleThoughtSpotPDF))
+	pdfPart.Write([]byte(encodedPDF))
+
+	writer.Close()
+}
+
+// sampleThoughtSpotData fills in a scheduled-report notification without attachments
+func sampleThoughtSpotData(now, nextRun time.Time) ThoughtSpotWebhookData {
+	data := ThoughtSpotWebhookData{
+		Users: []ThoughtSpotUser{
+			{
+				DisplayName: "John Doe",
+				Email:       "john.doe@thoughtspot.com",
+				UserID:      "user-12345",
+			},
+		},
+		SchemaVersion:    "v1",
+		SchemaType:       "SCHEDULED_REPORT",
+		NotificationType: "DELIVERY",
+	}
+
+	data.ScheduledReportWebhookNotification.ReportID = "report-67890"
+	data.ScheduledReportWebhookNotification.ReportName = "Monthly Sales Dashboard"
+	data.ScheduledReportWebhookNotification.ScheduleInfo.ScheduleID = "schedule-abc123"
+	data.ScheduledReportWebhookNotification.ScheduleInfo.ScheduleString = "monthly on 1st day at 9:00 AM"
+	data.ScheduledReportWebhookNotification.ScheduleInfo.NextRunTime = nextRun.Format(time.RFC3339)
+	data.ScheduledReportWebhookNotification.ScheduleInfo.Timezone = "America/New_York"
+	data.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryID = "delivery-xyz789"
+	data.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryTime = now.Format(time.RFC3339)
+	data.ScheduledReportWebhookNotification.DeliveryInfo.DeliveryStatus = "SUCCESS"
+	data.ScheduledReportWebhookNotification.DeliveryInfo.RecipientCount = 5
+	data.ScheduledReportWebhookNotification.ReportMetadata.PinboardID = "22a8f618-0b4f-4401-92db-ba029ee13486"
+	data.ScheduledReportWebhookNotification.ReportMetadata.PinboardName = "Sales Performance Dashboard"
+	data.ScheduledReportWebhookNotification.ReportMetadata.ReportURL = "http://thoughtspot.company.com/?utm_source=scheduled_report&utm_medium=webhook/#/pinboard/22a8f618-0b4f-4401-92db-ba029ee13486"
+
+	return data
+}
+
+// sampleThoughtSpotPDF is the report attached to ThoughtSpot payloads
+const sampleThoughtSpotPDF = `%PDF-1.4
 1 0 obj
 <<
 /Type /Catalog
@@ -830,13 +1170,6 @@ startxref
 351
 %%EOF`
 
-	// Encode PDF content as base64
-	encodedPDF := base64.StdEncoding.EncodeToString([]byte(samplePDF))
-	pdfPart.Write([]byte(encodedPDF))
-
-	writer.Close()
-}
-
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Health check request received")
 	response := map[string]interface{}{
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// XMLDocument is the parsed view of an XML or SOAP body
+type XMLDocument struct {
+	WellFormed  bool     `json:"wellFormed"`
+	Error       string   `json:"error,omitempty"`
+	Root        string   `json:"root,omitempty"`        // document element, e.g. "Envelope"
+	SOAPVersion string   `json:"soapVersion,omitempty"` // 1.1 or 1.2 for SOAP envelopes
+	SOAPAction  string   `json:"soapAction,omitempty"`  // SOAPAction header, or the action parameter of SOAP 1.2
+	BodyRoot    string   `json:"bodyRoot,omitempty"`    // first element inside soap:Body, or the document element
+	Tree        *XMLNode `json:"tree,omitempty"`
+}
+
+// XMLNode is one element of the tree
+type XMLNode struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	Children   []*XMLNode        `json:"children,omitempty"`
+}
+
+const flagInvalidXML = "invalid-xml"
+
+var soapNamespaces = map[string]string{
+	"http://schemas.xmlsoap.org/soap/envelope/": "1.1",
+	"http://www.w3.org/2003/05/soap-envelope":   "1.2",
+}
+
+// isXMLContentType reports whether the body should be parsed as XML
+func isXMLContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// parseXMLBody builds the tree and checks the body is well formed
+func parseXMLBody(r *http.Request, body []byte) *XMLDocument {
+	doc := &XMLDocument{}
+	root, err := parseXMLTree(body)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc
+	}
+	doc.WellFormed = true
+	doc.Tree = root
+	doc.Root = root.Name
+	doc.BodyRoot = root.Name
+
+	if version, ok := soapNamespaces[root.Namespace]; ok && root.Name == "Envelope" {
+		doc.SOAPVersion = version
+		doc.BodyRoot = ""
+		for _, child := range root.Children {
+			if child.Name == "Body" && child.Namespace == root.Namespace && len(child.Children) > 0 {
+				doc.BodyRoot = child.Children[0].Name
+			}
+		}
+
+		doc.SOAPAction = strings.Trim(r.Header.Get("SOAPAction"), `"`)
+		if doc.SOAPAction == "" {
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			doc.SOAPAction = params["action"]
+		}
+	}
+	return doc
+}
+
+func parseXMLTree(body []byte) (*XMLNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Non-UTF-8 text is kept as is; the structure is still checked
+		return input, nil
+	}
+
+	var root *XMLNode
+	var stack []*XMLNode
+	var text [][]byte // character data of each open element
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, fmt.Errorf("more than one root element (<%s> after </%s>)", t.Name.Local, root.Name)
+			}
+			node := &XMLNode{Name: t.Name.Local, Namespace: t.Name.Space}
+			for _, attr := range t.Attr {
+				if node.Attributes == nil {
+					node.Attributes = make(map[string]string)
+				}
+				name := attr.Name.Local
+				if attr.Name.Space != "" {
+					name = attr.Name.Space + ":" + name
+				}
+				node.Attributes[name] = attr.Value
+			}
+			if len(stack) == 0 {
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+			text = append(text, nil)
+
+		case xml.EndElement:
+			node := stack[len(stack)-1]
+			node.Text = string(bytes.TrimSpace(text[len(text)-1]))
+			stack = stack[:len(stack)-1]
+			text = text[:len(text)-1]
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, fmt.Errorf("text outside the root element")
+				}
+				continue
+			}
+			text[len(text)-1] = append(text[len(text)-1], t...)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsXMLContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/xml":                 true,
+		"text/xml; charset=utf-8":         true,
+		"application/soap+xml":            true,
+		"application/atom+xml":            true,
+		"application/json":                false,
+		"application/xml-dtd":             false,
+		"multipart/form-data; boundary=x": false,
+	}
+	for contentType, want := range tests {
+		if got := isXMLContentType(contentType); got != want {
+			t.Errorf("isXMLContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestParseXMLBody(t *testing.T) {
+	const soap11 = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Header/>
+  <soap:Body><m:GetPrice xmlns:m="urn:prices"><m:Item>Apples</m:Item></m:GetPrice></soap:Body>
+</soap:Envelope>`
+	const soap12 = `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><Notify/></env:Body></env:Envelope>`
+
+	tests := []struct {
+		name        string
+		contentType string
+		soapAction  string
+		body        string
+		wellFormed  bool
+		root        string
+		bodyRoot    string
+		soapVersion string
+		wantAction  string
+	}{
+		{"plain document", "application/xml", "", `<order id="7"><item>book</item></order>`, true, "order", "order", "", ""},
+		{"SOAP 1.1", "text/xml", `"urn:GetPrice"`, soap11, true, "Envelope", "GetPrice", "1.1", "urn:GetPrice"},
+		{"SOAP 1.2 action parameter", `application/soap+xml; action="urn:Notify"`, "", soap12, true, "Envelope", "Notify", "1.2", "urn:Notify"},
+		{"unclosed element", "application/xml", "", `<order><item></order>`, false, "", "", "", ""},
+		{"two roots", "application/xml", "", `<a/><b/>`, false, "", "", "", ""},
+		{"text outside root", "application/xml", "", `<a/>trailing`, false, "", "", "", ""},
+		{"empty", "application/xml", "", ``, false, "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook/soap", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.soapAction != "" {
+				r.Header.Set("SOAPAction", tt.soapAction)
+			}
+
+			doc := parseXMLBody(r, []byte(tt.body))
+			if doc.WellFormed != tt.wellFormed {
+				t.Fatalf("wellFormed = %v, want %v (%s)", doc.WellFormed, tt.wellFormed, doc.Error)
+			}
+			if !doc.WellFormed {
+				if doc.Error == "" {
+					t.Error("malformed document without an error")
+				}
+				return
+			}
+			if doc.Root != tt.root || doc.BodyRoot != tt.bodyRoot || doc.SOAPVersion != tt.soapVersion || doc.SOAPAction != tt.wantAction {
+				t.Errorf("got root %q, bodyRoot %q, soap %q, action %q", doc.Root, doc.BodyRoot, doc.SOAPVersion, doc.SOAPAction)
+			}
+		})
+	}
+}
+
+func TestParseXMLTree(t *testing.T) {
+	root, err := parseXMLTree([]byte(`<order xmlns="urn:shop" id="7"><item qty="2"> book </item><note/></order>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Name != "order" || root.Namespace != "urn:shop" || root.Attributes["id"] != "7" {
+		t.Errorf("root = %+v", root)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("got %d children, want 2", len(root.Children))
+	}
+	item := root.Children[0]
+	if item.Text != "book" || item.Attributes["qty"] != "2" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestInvalidXMLIsFlagged(t *testing.T) {
+	withHistory(t, nil)
+
+	r := httptest.NewRequest("POST", "/webhook/soap", strings.NewReader(`<order><item></order>`))
+	r.Header.Set("Content-Type", "application/xml")
+	handleWebhook(httptest.NewRecorder(), r)
+
+	requestsMux.RLock()
+	captured := requests[0]
+	requestsMux.RUnlock()
+	if !hasFlag(captured, flagInvalidXML) || captured.XML == nil || captured.XML.WellFormed {
+		t.Errorf("flags = %v, xml = %+v", captured.Flags, captured.XML)
+	}
+}
@@ -23,4 +23,4 @@ fi
 echo "Starting server on http://localhost:8080"
 echo "Press Ctrl+C to stop"
 echo ""
-go run cmd/webhook-test-server.go 
\ No newline at end of file
+go run ./cmd 
\ No newline at end of file
@@ -87,5 +87,5 @@ echo -e "\n\n=== Test case 8 completed ===\nCheck the web UI at $SERVER_URL to s
 
 echo "=== All test cases completed ==="
 echo "Check the web UI at $SERVER_URL to see all results."
-echo "Note: The server is configured to only process one file type per request,"
-echo "so in test case 6 (PDF + PNG + JSON), only one file type will be processed." 
\ No newline at end of file
+echo "Note: every part is captured, so test case 6 (PDF + PNG + JSON) shows both files."
+echo "To restrict file types, give the endpoint a multipart policy (allowedTypes, singleType)." 
\ No newline at end of file